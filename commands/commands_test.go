@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestFlagSetNames(t *testing.T) {
+	tests := []struct {
+		cmd  *flag.FlagSet
+		want string
+	}{
+		{UploadCmd, "upload"},
+		{NewUploadCmd(), "upload"},
+		{DownloadCmd, "download"},
+		{NewDownloadCmd(), "download"},
+		{PingCmd, "ping"},
+		{TestCmd, "test"},
+		{TraceCmd, "trace"},
+		{QualityCmd, "quality"},
+		{ServersCmd, "servers"},
+		{HistoryCmd, "history"},
+	}
+	for _, tt := range tests {
+		if got := tt.cmd.Name(); got != tt.want {
+			t.Errorf("Name() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -5,12 +5,43 @@ import (
 	"time"
 )
 
-var DownloadCmd = flag.NewFlagSet("download", flag.ExitOnError)
+var DownloadCmd = NewDownloadCmd()
 
-func init() {
-	DownloadCmd.String("url", "", "URL to download from (required)")
-	DownloadCmd.Duration("duration", time.Second*30, "Maximum download duration")
-	DownloadCmd.Int("concurrency", 4, "Number of concurrent download chunks")
-	DownloadCmd.String("output", "", "Output file path (optional)")
-	DownloadCmd.Bool("verbose", false, "Enable detailed output")
+// NewDownloadCmd returns a new set of the download command's flags, for
+// commands that run a download with options of their own
+func NewDownloadCmd() *flag.FlagSet {
+	cmd := flag.NewFlagSet("download", flag.ExitOnError)
+	cmd.String("url", "", "URL to download from (required)")
+	cmd.Duration("duration", time.Second*30, "Maximum download duration (0 = fetch each file once)")
+	cmd.Int("concurrency", 4, "Number of concurrent download chunks")
+	cmd.String("output", "", "Output file path (optional)")
+	cmd.Bool("verbose", false, "Enable detailed output")
+	cmd.String("unit", "mbps", "Speed display unit: mbps, mibps or both")
+	cmd.String("http", "", "Force the HTTP version: 1.1, or 2 to fail unless the server speaks HTTP/2 (default: negotiate)")
+	cmd.Int("max-retries", 5, "Consecutive failures on a test file before a worker moves to the next one; 0 retries forever")
+	cmd.Bool("watch-recovery", false, "Detect throughput drops and time how long until transfers resume")
+	cmd.Int64("seed", 0, "Seed for worker-to-URL assignment (0 = built-in order)")
+	cmd.Duration("single-stream", 0, "Portion of the duration spent measuring a single stream first (0 = off)")
+	cmd.Duration("warmup", 0, "Leading part of the test whose bytes are not counted (e.g. 2s)")
+	cmd.Bool("require-tls13", false, "Fail if the server does not negotiate TLS 1.3")
+	cmd.Bool("insecure", false, "Skip TLS certificate verification (for self-signed test servers)")
+	cmd.String("proxy", "", "Send requests through this proxy (e.g. socks5://host:1080, http://host:3128)")
+	cmd.String("tls-ciphers", "", "Comma-separated cipher suites to allow (e.g. TLS_AES_128_GCM_SHA256)")
+	cmd.String("format", "table", "Output format: table, markdown, json, csv or prometheus")
+	cmd.String("template", "", "Format results with this Go text/template file instead of --format")
+	cmd.String("out", "-", "Write results to a file, - for stdout or stderr")
+	cmd.String("max-bytes", "", "Stop once this much data has been received, e.g. on metered links (e.g. 500MB)")
+	cmd.Float64("min-download", 0, "Exit with an error if the speed is below this many Mbps, for alerting (0 = off)")
+	cmd.Int("repeat", 1, "Run the test this many times and summarize the speeds (JSON output becomes one line per run)")
+	cmd.Duration("interval", 0, "Time from the start of one --repeat run to the next (e.g. 60s)")
+	cmd.String("require-min-bytes", "", "Fail unless at least this much data is received (e.g. 50MB)")
+	cmd.Bool("cache-bust", false, "Add a random query parameter and no-cache header to every request")
+	cmd.Int("server", 0, "Test against this server ID from 'speedgo servers' (0 = built-in endpoints)")
+	cmd.String("server-list", "", "Server list --server is looked up in (default: built-in list)")
+	cmd.Bool("auto-server", false, "Time requests to the test file hosts first and download only from the fastest")
+	cmd.Bool("bufferbloat", false, "Measure idle and loaded latency to detect bufferbloat")
+	cmd.String("bufferbloat-target", "", "Host to ping for --bufferbloat (default: the host of the first test file)")
+	cmd.String("log", "", "Append the results as a JSON line to this file, for 'speedgo history'")
+	cmd.String("config", "", "JSON file of option values keyed by flag name; flags on the command line override it")
+	return cmd
 }
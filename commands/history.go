@@ -0,0 +1,9 @@
+package commands
+
+import "flag"
+
+var HistoryCmd = flag.NewFlagSet("history", flag.ExitOnError)
+
+func init() {
+	HistoryCmd.String("log", "", "File written by --log to summarize (may also be given as the first argument)")
+}
@@ -2,14 +2,44 @@ package commands
 
 import (
 	"flag"
+	"time"
 )
 
 var PingCmd = flag.NewFlagSet("ping", flag.ExitOnError)
 
 func init() {
-	PingCmd.String("targets", "cloudflare.com,google.com,amazon.com", "Comma-separated list of targets to ping")
+	PingCmd.String("targets", "", "Comma-separated list of targets to ping (default: built-in targets)")
+	PingCmd.String("target-sep", ",", "Separator between --targets entries; whitespace and newlines always separate too")
 	PingCmd.Int("count", 4, "Number of pings per target (default: 4)")
 	PingCmd.Duration("timeout", 1_000_000_000, "Timeout for each ping (e.g., 1s, 500ms)")
+	PingCmd.Duration("interval", time.Second, "Pause between probes to the same target (minimum 10ms)")
+	PingCmd.Bool("probe-timeout-grows", false, "Adapt the timeout to the observed RTT, starting from --timeout")
 	PingCmd.Int("concurrency", 3, "Number of concurrent pings (default: 3)")
+	PingCmd.Int("inflight", 1, "ICMP probes per target that may await a reply at once; sends stay --interval apart")
+	PingCmd.Int("dscp", 0, "DSCP value (0-63) to mark ICMP probes with, e.g. 46 for expedited forwarding")
+	PingCmd.Int("ttl", 0, "Outgoing TTL (IPv6 hop limit) of ICMP probes (default: system default)")
+	PingCmd.Int("size", 56, "ICMP echo payload size in bytes; warns when probes exceed the outgoing interface MTU")
+	PingCmd.Bool("df", false, "Set the don't-fragment bit so oversized --size probes fail instead of fragmenting (IPv4)")
 	PingCmd.Bool("verbose", false, "Enable detailed output")
+	PingCmd.String("color", "auto", "Colorize status glyphs: auto, always or never")
+	PingCmd.String("fail-on", "down", "Exit with an error when a target's status glyph is this bad: down, degraded or none")
+	PingCmd.Bool("interactive", false, "Ping continuously with a live table until q or Ctrl-C is pressed")
+	PingCmd.String("format", "table", "Output format: table, markdown, json, csv or prometheus")
+	PingCmd.String("template", "", "Format results with this Go text/template file instead of --format")
+	PingCmd.Bool("compact", false, "List targets in a dense multi-column grid")
+	PingCmd.String("out", "-", "Write results to a file, - for stdout or stderr")
+	PingCmd.Bool("streaming-stats", false, "Keep running statistics instead of every RTT (for very long runs)")
+	PingCmd.String("mode", "icmp", "Probe protocol: icmp, tcp (connect time) or both")
+	PingCmd.Int("port", 443, "Port to connect to with --mode=tcp or both")
+	PingCmd.Bool("ipv6", false, "Resolve and ping targets over IPv6 only")
+	PingCmd.Bool("6", false, "Shorthand for --ipv6")
+	PingCmd.Bool("flag-private", false, "Warn when a hostname resolves to a private or bogon address")
+	PingCmd.Bool("fail-private", false, "Like --flag-private, but also exit with an error")
+	PingCmd.Bool("allow-duplicates", false, "Keep targets that repeat or resolve to the same address as another")
+	PingCmd.Bool("stop-on-good", false, "Stop probing a target early once it is clearly healthy")
+	PingCmd.Int("good-replies", 3, "Consecutive fast replies with no loss needed for --stop-on-good")
+	PingCmd.Duration("good-rtt", 20*time.Millisecond, "RTT below which a reply counts as good for --stop-on-good")
+	PingCmd.Bool("discover-mtu", false, "Find the path MTU to each target with don't-fragment probes")
+	PingCmd.String("log", "", "Append the results as JSON lines to this file, for 'speedgo history'")
+	PingCmd.String("config", "", "JSON file of option values keyed by flag name; flags on the command line override it")
 }
@@ -0,0 +1,17 @@
+package commands
+
+import (
+	"flag"
+	"time"
+)
+
+var QualityCmd = flag.NewFlagSet("quality", flag.ExitOnError)
+
+func init() {
+	QualityCmd.String("targets", "", "Comma-separated list of targets to measure (default: built-in targets)")
+	QualityCmd.Int("count", 50, "Number of pings per target in the burst")
+	QualityCmd.Duration("interval", 20*time.Millisecond, "Pause between pings, like the packet rate of a voice call (minimum 10ms)")
+	QualityCmd.Duration("timeout", time.Second, "Timeout for each ping (e.g., 1s, 500ms)")
+	QualityCmd.Int("concurrency", 3, "Number of targets measured at once")
+	QualityCmd.Bool("verbose", false, "Enable detailed output")
+}
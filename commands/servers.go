@@ -0,0 +1,16 @@
+package commands
+
+import (
+	"flag"
+	"time"
+)
+
+var ServersCmd = flag.NewFlagSet("servers", flag.ExitOnError)
+
+func init() {
+	ServersCmd.String("list-url", "", "JSON server list in LibreSpeed format (default: built-in list)")
+	ServersCmd.Int("count", 3, "TCP pings per server")
+	ServersCmd.Duration("timeout", 2*time.Second, "Time to wait for each ping")
+	ServersCmd.Int("concurrency", 8, "Servers pinged at the same time")
+	ServersCmd.Int("limit", 10, "Show only the closest servers (0 = all)")
+}
@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"flag"
+	"time"
+)
+
+var TestCmd = flag.NewFlagSet("test", flag.ExitOnError)
+
+func init() {
+	TestCmd.String("ping-target", "", "Host to measure latency to (default: the first built-in ping target)")
+	TestCmd.Int("count", 10, "Number of pings for the latency test")
+	TestCmd.Duration("duration", 10*time.Second, "Duration of each of the download and upload tests")
+	TestCmd.Int("concurrency", 4, "Number of concurrent streams for download and upload")
+	TestCmd.String("unit", "mbps", "Speed display unit: mbps, mibps or both")
+	TestCmd.String("format", "table", "Output format: table or prometheus")
+	TestCmd.String("out", "-", "Write results to a file, - for stdout or stderr")
+	TestCmd.Bool("bidirectional", false, "Run download and upload at the same time and measure latency while both saturate the link")
+	TestCmd.String("log", "", "Append the results as JSON lines to this file, for 'speedgo history'")
+	TestCmd.Bool("verbose", false, "Enable detailed output and show each test's full results")
+}
@@ -0,0 +1,17 @@
+package commands
+
+import (
+	"flag"
+	"time"
+)
+
+var TraceCmd = flag.NewFlagSet("trace", flag.ExitOnError)
+
+func init() {
+	TraceCmd.String("target", "", "Host to trace the route to (may also be given as the first argument)")
+	TraceCmd.Int("max-hops", 30, "Largest TTL to probe before giving up")
+	TraceCmd.Int("queries", 3, "Probes sent per hop")
+	TraceCmd.Duration("timeout", time.Second, "Time to wait for each probe's reply")
+	TraceCmd.Bool("numeric", false, "Print hop addresses without resolving hostnames")
+	TraceCmd.Bool("ipv6", false, "Resolve and trace the target over IPv6")
+}
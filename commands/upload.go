@@ -2,11 +2,42 @@ package commands
 
 import "flag"
 
-var UploadCmd = flag.NewFlagSet("download", flag.ExitOnError)
+var UploadCmd = NewUploadCmd()
 
-func init() {
-	UploadCmd.String("url", "http://speedtest.example.com", "Base URL of the speed test server (default: example server)")
-	UploadCmd.Int("concurrency", 4, "Number of concurrent downloads (default: 4)")
-	UploadCmd.Int("duration", 10, "Test duration in seconds")
-	UploadCmd.Bool("verbose", false, "Enable detailed output")
+// NewUploadCmd returns a new set of the upload command's flags, for
+// commands that run an upload with options of their own
+func NewUploadCmd() *flag.FlagSet {
+	cmd := flag.NewFlagSet("upload", flag.ExitOnError)
+	cmd.String("url", "", "Endpoint to POST the upload payload to (default: built-in endpoint)")
+	cmd.Int("concurrency", 4, "Number of concurrent uploads (default: 4)")
+	cmd.Int("duration", 10, "Test duration in seconds")
+	cmd.Duration("warmup", 0, "Leading part of the test whose bytes are not counted (e.g. 2s)")
+	cmd.Bool("verbose", false, "Enable detailed output")
+	cmd.String("unit", "mbps", "Speed display unit: mbps, mibps or both")
+	cmd.String("http", "", "Force the HTTP version: 1.1, or 2 to fail unless the server speaks HTTP/2 (default: negotiate)")
+	cmd.Int64("seed", 0, "Seed for generated payload data (0 = random)")
+	cmd.Bool("require-tls13", false, "Fail if the server does not negotiate TLS 1.3")
+	cmd.Bool("insecure", false, "Skip TLS certificate verification (for self-signed test servers)")
+	cmd.String("proxy", "", "Send requests through this proxy (e.g. socks5://host:1080, http://host:3128)")
+	cmd.String("tls-ciphers", "", "Comma-separated cipher suites to allow (e.g. TLS_AES_128_GCM_SHA256)")
+	cmd.String("format", "table", "Output format: table, markdown, json, csv or prometheus")
+	cmd.String("template", "", "Format results with this Go text/template file instead of --format")
+	cmd.String("out", "-", "Write results to a file, - for stdout or stderr")
+	cmd.String("file", "", "Upload the contents of this file instead of generated data")
+	cmd.Float64("min-upload", 0, "Exit with an error if the speed is below this many Mbps, for alerting (0 = off)")
+	cmd.Int("repeat", 1, "Run the test this many times and summarize the speeds (JSON output becomes one line per run)")
+	cmd.Duration("interval", 0, "Time from the start of one --repeat run to the next (e.g. 60s)")
+	cmd.String("max-bytes", "", "Stop once this much data has been sent, e.g. on metered links (e.g. 500MB)")
+	cmd.String("chunk-size", "1MiB", "Bytes sent per upload request (e.g. 256KiB, 4MB)")
+	cmd.String("data", "random", "Generated payload content: random or zeros")
+	cmd.Bool("compress", false, "Gzip request bodies (pair with --data=zeros to see the effect)")
+	cmd.Bool("bufferbloat", false, "Measure idle and loaded latency to detect bufferbloat")
+	cmd.String("bufferbloat-target", "", "Host to ping for --bufferbloat (default: the upload host)")
+	cmd.Bool("burst", false, "Upload for a short window and report the peak 100ms rate instead of the average (not with --duration)")
+	cmd.Bool("rps", false, "Send small requests and report requests/second and per-request latency")
+	cmd.Int("server", 0, "Test against this server ID from 'speedgo servers' (0 = built-in endpoints)")
+	cmd.String("server-list", "", "Server list --server is looked up in (default: built-in list)")
+	cmd.String("log", "", "Append the results as a JSON line to this file, for 'speedgo history'")
+	cmd.String("config", "", "JSON file of option values keyed by flag name; flags on the command line override it")
+	return cmd
 }
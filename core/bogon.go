@@ -0,0 +1,47 @@
+// Package core bogon.go
+package core
+
+import "net"
+
+// bogonRanges are reserved ranges that should never be the answer for a
+// public hostname, beyond the private, loopback and link-local checks the
+// net package already provides
+var bogonRanges = mustParseCIDRs(
+	"0.0.0.0/8",       // "This" network
+	"100.64.0.0/10",   // Carrier-grade NAT
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // TEST-NET-1
+	"198.18.0.0/15",   // Benchmarking
+	"198.51.100.0/24", // TEST-NET-2
+	"203.0.113.0/24",  // TEST-NET-3
+	"240.0.0.0/4",     // Reserved, including broadcast
+	"64:ff9b:1::/48",  // Local-use NAT64
+	"2001:db8::/32",   // Documentation
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// isPrivateAddr reports whether ip is in an RFC 1918/4193 private,
+// loopback, link-local, unspecified or bogon range
+func isPrivateAddr(ip net.IP) bool {
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range bogonRanges {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsPrivateAddr(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		// RFC 1918
+		{"10.0.0.1", true},
+		{"172.16.5.4", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		// Carrier-grade NAT 100.64.0.0/10
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"100.128.0.1", false},
+		{"100.63.255.255", false},
+		// Loopback
+		{"127.0.0.1", true},
+		{"::1", true},
+		// Link-local
+		{"169.254.10.20", true},
+		{"fe80::1", true},
+		// Unique local fc00::/7
+		{"fc00::1", true},
+		{"fd12:3456:789a::1", true},
+		// Other bogons
+		{"0.0.0.0", true},
+		{"192.0.2.10", true},
+		{"2001:db8::1", true},
+		// Public
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"2001:4860:4860::8888", false},
+		{"2606:4700:4700::1111", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPrivateAddr(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("isPrivateAddr(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadSpeedUsesClock(t *testing.T) {
+	const size = 250_000
+	clock := newFakeClock(time.Unix(0, 0))
+	payload := strings.Repeat("x", size)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The transfer takes exactly two seconds on the test clock
+		clock.Advance(2 * time.Second)
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	stats := measureDownloadSpeed(context.Background(), &DownloadConfig{
+		URLs:        []string{server.URL},
+		Concurrency: 1,
+		Clock:       clock,
+	})
+
+	if stats.Error != nil {
+		t.Fatalf("Error = %v", stats.Error)
+	}
+	if stats.Duration != 2*time.Second {
+		t.Errorf("Duration = %v, want 2s", stats.Duration)
+	}
+	if want := float64(size) * 8 / 2 / 1e6; stats.Speed != want {
+		t.Errorf("Speed = %v, want %v", stats.Speed, want)
+	}
+	if stats.Phases.Requests != 1 || stats.Phases.Total != 2*time.Second {
+		t.Errorf("Phases = %+v, want 1 request taking 2s", stats.Phases)
+	}
+}
+
+func TestFakeClockAfter(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	tests := []struct {
+		name    string
+		after   time.Duration
+		advance time.Duration
+		fired   bool
+	}{
+		{"zero fires at once", 0, 0, true},
+		{"not yet due", 2 * time.Second, time.Second, false},
+		{"due", 2 * time.Second, 2 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := clock.After(tt.after)
+			clock.Advance(tt.advance)
+			select {
+			case <-ch:
+				if !tt.fired {
+					t.Error("timer fired early")
+				}
+			default:
+				if tt.fired {
+					t.Error("timer did not fire")
+				}
+			}
+		})
+	}
+}
+
+func TestPauseUsesClock(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	pause := &pauseController{clock: clock, out: io.Discard}
+
+	pause.toggle()
+	clock.Advance(3 * time.Second)
+	if got := pause.pausedFor(); got != 3*time.Second {
+		t.Errorf("pausedFor while paused = %v, want 3s", got)
+	}
+
+	pause.toggle()
+	clock.Advance(5 * time.Second)
+	if got := pause.pausedFor(); got != 3*time.Second {
+		t.Errorf("pausedFor after resuming = %v, want 3s", got)
+	}
+}
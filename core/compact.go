@@ -0,0 +1,59 @@
+// Package core compact.go
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultTerminalWidth is used when the output is not a terminal
+const defaultTerminalWidth = 80
+
+// printCompactResults lists every target as a short "target avg/loss" cell
+// in a multi-column grid, which keeps very wide sweeps on one screen
+func printCompactResults(w io.Writer, results []PingResult, color bool) {
+	type cell struct {
+		status PingStatus
+		text   string
+	}
+
+	cells := make([]cell, len(results))
+	cellWidth := 0
+	for i, result := range results {
+		text := result.label() + " down"
+		if result.Received > 0 {
+			lossPercent := result.lossPercent()
+			text = fmt.Sprintf("%s %.1fms/%.0f%%", result.label(),
+				float64(result.AvgRTT.Microseconds())/1000, lossPercent)
+		}
+		cells[i] = cell{status: classifyResult(result), text: text}
+		if w := utf8.RuneCountInString(text) + 2; w > cellWidth {
+			cellWidth = w // glyph and separating space
+		}
+	}
+
+	width := defaultTerminalWidth
+	if f, ok := w.(*os.File); ok {
+		if termWidth, err := terminalWidth(int(f.Fd())); err == nil && termWidth > 0 {
+			width = termWidth
+		}
+	}
+	columns := width / (cellWidth + 2)
+	if columns < 1 {
+		columns = 1
+	}
+
+	fmt.Fprintln(w, "\nPING STATISTICS")
+	for i, c := range cells {
+		padding := cellWidth - utf8.RuneCountInString(c.text) - 2
+		fmt.Fprintf(w, "%s %s%s", statusGlyph(c.status, color), c.text, strings.Repeat(" ", padding))
+		if (i+1)%columns == 0 || i == len(cells)-1 {
+			fmt.Fprintln(w)
+		} else {
+			fmt.Fprint(w, "  ")
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Package core configfile.go
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// applyConfigFile sets the flags of cmd not in set from the JSON file named
+// by its --config flag, if it has one. The file holds one object keyed by
+// flag name, with each value written as it would be on the command line:
+//
+//	{"targets": "1.1.1.1,8.8.8.8", "count": 10, "timeout": "2s", "verbose": true}
+func applyConfigFile(cmd *flag.FlagSet, set map[string]bool) error {
+	configFlag := cmd.Lookup("config")
+	if configFlag == nil || configFlag.Value.String() == "" {
+		return nil
+	}
+	path := configFlag.Value.String()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var values map[string]any
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if name == "config" || cmd.Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown option %q for %s", path, name, cmd.Name())
+		}
+		if set[name] {
+			continue
+		}
+
+		var text string
+		switch v := values[name].(type) {
+		case string:
+			text = v
+		case json.Number:
+			text = v.String()
+		case bool:
+			text = strconv.FormatBool(v)
+		default:
+			return fmt.Errorf("config file %s: option %q must be a string, number or boolean", path, name)
+		}
+		if err := cmd.Set(name, text); err != nil {
+			return fmt.Errorf("config file %s: invalid value %q for option %q: %w", path, text, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSourcesTestCmd returns a flag set with a flag of each kind, named
+// like the ping command so its environment variables start SPEEDGO_PING_
+func newSourcesTestCmd() *flag.FlagSet {
+	cmd := flag.NewFlagSet("ping", flag.ContinueOnError)
+	cmd.String("config", "", "")
+	cmd.String("targets", "8.8.8.8", "")
+	cmd.Int("count", 4, "")
+	cmd.Duration("timeout", time.Second, "")
+	cmd.Bool("verbose", false, "")
+	return cmd
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // Contents of the config file, or "" for no --config
+		args    []string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "no config file",
+			want: map[string]string{"targets": "8.8.8.8", "count": "4"},
+		},
+		{
+			name: "every value type",
+			file: `{"targets": "1.1.1.1,9.9.9.9", "count": 10, "timeout": "2s", "verbose": true}`,
+			want: map[string]string{"targets": "1.1.1.1,9.9.9.9", "count": "10", "timeout": "2s", "verbose": "true"},
+		},
+		{
+			name: "command line wins",
+			file: `{"count": 10, "timeout": "2s"}`,
+			args: []string{"--count=3"},
+			want: map[string]string{"count": "3", "timeout": "2s"},
+		},
+		{
+			name:    "unknown option",
+			file:    `{"cuont": 10}`,
+			wantErr: `unknown option "cuont" for ping`,
+		},
+		{
+			name:    "config cannot name another file",
+			file:    `{"config": "other.json"}`,
+			wantErr: `unknown option "config"`,
+		},
+		{
+			name:    "invalid value",
+			file:    `{"timeout": "soon"}`,
+			wantErr: `invalid value "soon" for option "timeout"`,
+		},
+		{
+			name:    "unsupported type",
+			file:    `{"targets": ["1.1.1.1"]}`,
+			wantErr: "must be a string, number or boolean",
+		},
+		{
+			name:    "not JSON",
+			file:    `count = 10`,
+			wantErr: "parsing config file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newSourcesTestCmd()
+			args := tt.args
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "speedgo.json")
+				if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				args = append([]string{"--config=" + path}, args...)
+			}
+			if err := cmd.Parse(args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyFlagSources(cmd)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := cmd.Lookup(name).Value.String(); got != want {
+					t.Errorf("--%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyConfigFileMissing(t *testing.T) {
+	cmd := newSourcesTestCmd()
+	if err := cmd.Parse([]string{"--config=" + filepath.Join(t.TempDir(), "missing.json")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyFlagSources(cmd); err == nil || !strings.Contains(err.Error(), "reading config file") {
+		t.Errorf("error = %v, want a read error", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Package core csv.go
+package core
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvEncoder writes results as CSV with a header row, for spreadsheets and
+// scripts
+type csvEncoder struct {
+	noHeader bool // Rows only, to follow output that already has the header
+}
+
+// csvMilliseconds formats a duration like the table does: milliseconds
+// with one decimal
+func csvMilliseconds(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d.Microseconds())/1000)
+}
+
+func (e csvEncoder) EncodePing(w io.Writer, config *PingConfig, results []PingResult) error {
+	cw := csv.NewWriter(w)
+	if !e.noHeader {
+		cw.Write([]string{"target", "min_ms", "avg_ms", "max_ms", "jitter_ms", "loss_pct"})
+	}
+	for _, result := range results {
+		row := []string{result.label(), "", "", "", "", fmt.Sprintf("%.1f", result.lossPercent())}
+		if result.Received > 0 {
+			row[1] = csvMilliseconds(result.MinRTT)
+			row[2] = csvMilliseconds(result.AvgRTT)
+			row[3] = csvMilliseconds(result.MaxRTT)
+			row[4] = csvMilliseconds(result.Jitter)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (e csvEncoder) EncodeDownload(w io.Writer, config *DownloadConfig, stats DownloadStats) error {
+	return writeTransferCSV(w, !e.noHeader, stats.BytesReceived, stats.Duration, stats.Speed, stats.Error)
+}
+
+func (e csvEncoder) EncodeUpload(w io.Writer, config *UploadConfig, stats UploadStats) error {
+	return writeTransferCSV(w, !e.noHeader, stats.BytesSent, stats.Duration, stats.Speed, stats.Error)
+}
+
+// writeTransferCSV writes the single summary row of a download or upload,
+// after the header row if header is set
+func writeTransferCSV(w io.Writer, header bool, bytes int64, d time.Duration, speed float64, err error) error {
+	cw := csv.NewWriter(w)
+	if header {
+		cw.Write([]string{"bytes", "duration_s", "speed_mbps", "error"})
+	}
+	cw.Write([]string{
+		strconv.FormatInt(bytes, 10),
+		fmt.Sprintf("%.1f", d.Seconds()),
+		fmt.Sprintf("%.2f", speed),
+		errorString(err),
+	})
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// readCSV parses encoded output back into records
+func readCSV(t *testing.T, data []byte) [][]string {
+	t.Helper()
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("reading back CSV %q: %v", data, err)
+	}
+	return records
+}
+
+func TestCSVPingReadBack(t *testing.T) {
+	results := []PingResult{
+		{Target: "a, \"quoted\" host", Mode: pingModeICMP, Sent: 4, Received: 4,
+			MinRTT: 10 * time.Millisecond, AvgRTT: 12500 * time.Microsecond, MaxRTT: 15 * time.Millisecond, Jitter: 2 * time.Millisecond},
+		{Target: "down.example", Mode: pingModeTCP, Sent: 4, Lost: 4},
+	}
+	var buf bytes.Buffer
+	if err := (csvEncoder{}).EncodePing(&buf, &PingConfig{}, results); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"target", "min_ms", "avg_ms", "max_ms", "jitter_ms", "loss_pct"},
+		{"a, \"quoted\" host", "10.0", "12.5", "15.0", "2.0", "0.0"},
+		{"down.example (tcp)", "", "", "", "", "100.0"},
+	}
+	got := readCSV(t, buf.Bytes())
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("read back %q, want %q", got, want)
+	}
+}
+
+func TestCSVTransferReadBack(t *testing.T) {
+	stats := UploadStats{
+		BytesSent: 25_000_000,
+		Duration:  4 * time.Second,
+		Speed:     50,
+		Error:     errors.New("upload error: unexpected status 500, \"oops\""),
+	}
+	tests := []struct {
+		name    string
+		encoder csvEncoder
+		want    [][]string
+	}{
+		{"with header", csvEncoder{}, [][]string{
+			{"bytes", "duration_s", "speed_mbps", "error"},
+			{"25000000", "4.0", "50.00", "upload error: unexpected status 500, \"oops\""},
+		}},
+		{"rows only", csvEncoder{noHeader: true}, [][]string{
+			{"25000000", "4.0", "50.00", "upload error: unexpected status 500, \"oops\""},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.encoder.EncodeUpload(&buf, &UploadConfig{}, stats); err != nil {
+				t.Fatal(err)
+			}
+			got := readCSV(t, buf.Bytes())
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("read back %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// Package core datacap.go
+package core
+
+import (
+	"fmt"
+	"io"
+)
+
+// parseMaxBytes parses --max-bytes; an empty value means no cap
+func parseMaxBytes(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	limit, err := parseByteSize(s)
+	if err != nil {
+		return 0, fmt.Errorf("parsing --max-bytes: %w", err)
+	}
+	if limit == 0 {
+		return 0, fmt.Errorf("invalid --max-bytes %q (expected more than 0 bytes)", s)
+	}
+	return limit, nil
+}
+
+// printDataUsed reports the data a capped transfer used, including bytes
+// excluded from the speed by --warmup
+func printDataUsed(w io.Writer, used, limit int64, capped bool) {
+	fmt.Fprintf(w, "Data used: %.2f MB of %.2f MB cap\n",
+		float64(used)/(1024*1024), float64(limit)/(1024*1024))
+	if capped {
+		fmt.Fprintln(w, "Data cap reached, test stopped early")
+	}
+}
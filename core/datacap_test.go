@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMaxBytes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr string
+	}{
+		{"", 0, ""},
+		{"500", 500, ""},
+		{"64KiB", 64 << 10, ""},
+		{"50MB", 50 * 1000 * 1000, ""},
+		{"0", 0, "invalid --max-bytes \"0\""},
+		{"0MB", 0, "invalid --max-bytes \"0MB\""},
+		{"-1", 0, "parsing --max-bytes"},
+		{"lots", 0, "parsing --max-bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseMaxBytes(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseMaxBytes(%q) error = %v, want one containing %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseMaxBytes(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadStopsAtDataCap(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	const limit = 100 * 1024
+	stats := measureUploadSpeed(context.Background(), &UploadConfig{
+		URL:         server.URL,
+		Duration:    10 * time.Second,
+		Concurrency: 4,
+		ChunkSize:   64 * 1024,
+		MaxBytes:    limit,
+	})
+
+	if !stats.DataCapped {
+		t.Error("DataCapped = false, want true")
+	}
+	if stats.DataUsed > limit {
+		t.Errorf("DataUsed = %d, want at most the %d byte cap", stats.DataUsed, limit)
+	}
+	if stats.Error != nil {
+		t.Errorf("Error = %v, want none for requests cut off by the cap", stats.Error)
+	}
+}
+
+func TestDownloadSingleStreamUsesWholeCap(t *testing.T) {
+	server := newTestFileServer(t, 1<<20, 0, 0)
+
+	start := time.Now()
+	stats := measureDownload(context.Background(), &DownloadConfig{
+		URLs:         []string{server.URL},
+		Duration:     10 * time.Second,
+		SingleStream: 5 * time.Second,
+		Concurrency:  4,
+		MaxBytes:     64 * 1024,
+	})
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("returned after %v, want the aggregate phase skipped", elapsed)
+	}
+	if !stats.DataCapped {
+		t.Error("DataCapped = false, want true")
+	}
+	if len(stats.Workers) != 1 {
+		t.Errorf("%d workers reported, want only the single stream", len(stats.Workers))
+	}
+}
@@ -0,0 +1,24 @@
+// Package core defaults.go
+package core
+
+// Built-in endpoints used when the user does not supply their own. They are
+// exported so other front ends can share and override the same defaults.
+var (
+	// DefaultPingTargets are pinged when --targets is not given
+	DefaultPingTargets = []string{"cloudflare.com", "google.com", "amazon.com"}
+
+	// DefaultDownloadURLs are test files from various CDNs that download
+	// workers are spread across
+	DefaultDownloadURLs = []string{
+		"https://speed.cloudflare.com/__down?bytes=25000000", // 25MB test file
+		"https://cdn.jsdelivr.net/gh/librespeed/speedtest-files@master/random4000x4000.jpg",
+		"https://proof.ovh.net/files/100Mb.dat",
+	}
+
+	// DefaultUploadURL receives the generated upload payload
+	DefaultUploadURL = "https://speed.cloudflare.com/__up"
+
+	// DefaultServerListURL is the LibreSpeed server list used by the
+	// servers command and --server
+	DefaultServerListURL = "https://librespeed.org/backend-servers/servers.php"
+)
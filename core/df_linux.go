@@ -0,0 +1,30 @@
+// Package core df_linux.go
+package core
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// setDontFragment sets the IPv4 don't-fragment bit on every packet sent on
+// conn, so oversized probes fail instead of being fragmented
+func setDontFragment(conn *net.IPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("accessing socket: %w", err)
+	}
+
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_DO)
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		return fmt.Errorf("setting don't-fragment: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+// Package core df_other.go
+package core
+
+import (
+	"errors"
+	"net"
+)
+
+// setDontFragment is not supported on this platform
+func setDontFragment(conn *net.IPConn) error {
+	return errors.New("setting the don't-fragment bit is only supported on Linux")
+}
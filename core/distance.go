@@ -0,0 +1,25 @@
+// Package core distance.go
+package core
+
+import "time"
+
+// fiberKmPerMs is how far light travels in optical fiber per millisecond,
+// about two thirds of c
+const fiberKmPerMs = 200.0
+
+// routeStretch is how much longer than the great-circle path a typical
+// route is once detours, queueing and processing are included
+const routeStretch = 2.0
+
+// estimateDistance turns an average RTT into a rough great-circle distance
+// range in km. The upper bound assumes the whole RTT is spent in fiber on a
+// straight path; the lower bound allows for routeStretch. It is a sanity
+// indicator for unexpectedly distant routing, not a geolocation.
+func estimateDistance(avgRTT time.Duration) (minKm, maxKm float64) {
+	if avgRTT <= 0 {
+		return 0, 0
+	}
+	oneWayMs := float64(avgRTT.Microseconds()) / 1000 / 2
+	maxKm = oneWayMs * fiberKmPerMs
+	return maxKm / routeStretch, maxKm
+}
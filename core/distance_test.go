@@ -0,0 +1,31 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateDistance(t *testing.T) {
+	tests := []struct {
+		rtt              time.Duration
+		wantMin, wantMax float64
+	}{
+		{0, 0, 0},
+		{-time.Millisecond, 0, 0},
+		{time.Millisecond, 50, 100},
+		{10 * time.Millisecond, 500, 1000},
+		{25500 * time.Microsecond, 1275, 2550},
+		{150 * time.Millisecond, 7500, 15000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rtt.String(), func(t *testing.T) {
+			minKm, maxKm := estimateDistance(tt.rtt)
+			if minKm != tt.wantMin || maxKm != tt.wantMax {
+				t.Errorf("estimateDistance(%v) = %v-%v km, want %v-%v km", tt.rtt, minKm, maxKm, tt.wantMin, tt.wantMax)
+			}
+			if minKm > maxKm {
+				t.Errorf("estimateDistance(%v): lower bound %v above upper bound %v", tt.rtt, minKm, maxKm)
+			}
+		})
+	}
+}
@@ -3,10 +3,12 @@ package core
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"speedgo/commands"
 	"strings"
 	"sync"
@@ -16,24 +18,98 @@ import (
 
 // DownloadConfig stores download test configuration
 type DownloadConfig struct {
-	Duration    time.Duration
-	Concurrency int
-	Verbose     bool
+	Duration      time.Duration
+	Concurrency   int
+	Verbose       bool
+	WatchRecovery bool
+	Seed          int64         // Non-zero seeds the package RNG for reproducible runs
+	SingleStream  time.Duration // Part of Duration spent measuring one stream alone
+	Warmup        time.Duration // Start of each phase excluded from the speed
+	Unit          string        // Speed display unit: mbps, mibps or both
+	HTTPVersion   string        // Forced HTTP version, or "" to negotiate
+	MaxRetries    int           // Consecutive failures per file before moving on; 0 retries forever
+	RequireTLS13  bool
+	TLSCiphers    []uint16 // Allowed cipher suites; empty allows the Go defaults
+	Insecure      bool     // Skip TLS certificate verification
+	Proxy         *url.URL // Proxy for all requests; nil falls back to the environment
+	Format        string
+	Template      string   // text/template file used instead of Format
+	MinBytes      int64    // Fail the run if fewer bytes are received
+	MaxBytes      int64    // Stop once this many bytes were received; 0 is no cap
+	MinSpeed      float64  // Fail the run below this speed in Mbps; 0 disables the check
+	CacheBust     bool     // Make every request unique so caches cannot answer it
+	Out           string   // Results destination: "-" (stdout), "stderr" or a file path
+	Clock         Clock    // Time source for speed calculations; nil uses the system clock
+	URLs          []string // Test files; nil uses DefaultDownloadURLs
+	Server        int      // Server list ID to test against, 0 for the built-in URLs
+	ServerList    string   // Server list URL for Server
+	AutoServer    bool     // Use only the test file whose host answers fastest
+
+	Repeat   int           // Number of runs
+	Interval time.Duration // Time from the start of one run to the next
+	Log      string        // JSON Lines file each run is appended to, or ""
+
+	// Bufferbloat measures the RTT to BufferbloatTarget (default: the host
+	// of the first test file) before and during the test
+	Bufferbloat       bool
+	BufferbloatTarget string
+
+	pause *pauseController // Space bar pause control, set on interactive terminals
 }
 
+// ErrNoServersReachable is returned when every worker failed its first
+// request before any data arrived
+var ErrNoServersReachable = errors.New("no test servers reachable")
+
+// ErrRetriesExhausted is reported by a worker that gave up after
+// --max-retries consecutive failures on every test file
+var ErrRetriesExhausted = errors.New("retries exhausted on every test file")
+
+// Download workers back off exponentially between failed requests
+const (
+	initialRetryBackoff = time.Second
+	maxRetryBackoff     = 8 * time.Second
+)
+
+// ErrInsufficientData is returned when a download completes without an error
+// but received less than --require-min-bytes
+var ErrInsufficientData = errors.New("received less data than required")
+
+// ErrBelowMinSpeed is returned after the results are written when the
+// measured speed is under --min-download or --min-upload
+var ErrBelowMinSpeed = errors.New("speed below the required minimum")
+
 // DownloadStats stores download speed statistics
 type DownloadStats struct {
 	BytesReceived int64
 	Duration      time.Duration
 	Speed         float64 // Speed in Mbps
 	Error         error
-}
+	Recoveries    []RecoveryEvent
+	Protocols     []string // HTTP protocol versions negotiated with the servers
+
+	SingleStreamSpeed float64 // Speed in Mbps of the single-stream phase, if run
+	RateLimited       int64   // Number of 429 responses received
+	Phases            RequestPhases
+
+	// CacheHits counts responses whose Age, X-Cache or CF-Cache-Status
+	// header marked them as served from a cache, out of CacheReported
+	// responses that carried any such header
+	CacheHits     int64
+	CacheReported int64
+
+	Workers []WorkerStats // Bytes and speed of each worker
 
-// Default test files from various CDNs
-var defaultTestFiles = []string{
-	"https://speed.cloudflare.com/__down?bytes=25000000", // 25MB test file
-	"https://cdn.jsdelivr.net/gh/librespeed/speedtest-files@master/random4000x4000.jpg",
-	"https://proof.ovh.net/files/100Mb.dat",
+	Latency *LoadedLatency // Set when --bufferbloat is used
+
+	// DataUsed counts every byte received, including the warmup, and
+	// DataCapped is set when it reached --max-bytes
+	DataUsed   int64
+	DataCapped bool
+
+	// Cancelled is set when the run was interrupted before its duration
+	// ended; the stats cover what was received until then
+	Cancelled bool
 }
 
 func RunDownload(ctx context.Context, args []string) error {
@@ -42,56 +118,279 @@ func RunDownload(ctx context.Context, args []string) error {
 		return fmt.Errorf("parsing download config: %w", err)
 	}
 
-	fmt.Printf("Starting download speed test (Duration: %v, Concurrent streams: %d)\n",
-		config.Duration, config.Concurrency)
+	encoder, err := newEncoder(config.Format, config.Template)
+	if err != nil {
+		return err
+	}
+	if config.Insecure {
+		warnInsecure()
+	}
+
+	if config.Server != 0 {
+		server, err := findServer(ctx, speedClientConfig{
+			RequireTLS13: config.RequireTLS13,
+			TLSCiphers:   config.TLSCiphers,
+			Insecure:     config.Insecure,
+			Proxy:        config.Proxy,
+		}, config.ServerList, config.Server)
+		if err != nil {
+			return err
+		}
+		config.URLs = []string{server.DownloadURL}
+		fmt.Fprintf(bannerWriter(config.Format), "Testing against server %d: %s (%s)\n", server.ID, server.Name, server.Host)
+	}
+	if config.AutoServer {
+		if err := selectFastestURL(ctx, config); err != nil {
+			return err
+		}
+	}
+
+	if config.Duration == 0 {
+		fmt.Fprintf(bannerWriter(config.Format), "Starting single-pass download test (Concurrent streams: %d)\n", config.Concurrency)
+	} else {
+		fmt.Fprintf(bannerWriter(config.Format), "Starting download speed test (Duration: %v, Concurrent streams: %d)\n",
+			config.Duration, config.Concurrency)
+	}
+
+	pause, restore := startPauseControl(clockOrReal(config.Clock), bannerWriter(config.Format))
+	defer restore()
+	config.pause = pause
+
+	if config.Repeat > 1 {
+		return runDownloadRepeated(ctx, config, encoder)
+	}
+
+	stats := measureDownload(ctx, config)
+	if errors.Is(stats.Error, ErrNoServersReachable) {
+		return stats.Error
+	}
+
+	err = writeResults(config.Out, func(w io.Writer) error {
+		return encoder.EncodeDownload(config.pause.writer(w), config, stats)
+	})
+	if err != nil {
+		return err
+	}
+	if err := logResults(config.Log, downloadRecord(time.Now(), stats)); err != nil {
+		return err
+	}
+	return checkDownload(config, stats)
+}
+
+// runDownloadRepeated measures config.Repeat times, config.Interval apart,
+// and follows the results of every run with a summary of their speeds. A
+// run that fails any threshold fails the command.
+func runDownloadRepeated(ctx context.Context, config *DownloadConfig, encoder Encoder) error {
+	var runs []DownloadStats
+	first, rest := repeatEncoders(encoder)
+	err := writeResults(config.Out, func(w io.Writer) error {
+		var previous time.Duration
+		for run := 1; run <= config.Repeat; run++ {
+			encoder := first
+			if run > 1 {
+				encoder = rest
+				if err := waitForRun(ctx, config.pause.writer(bannerWriter(config.Format)), run, config.Repeat, config.Interval, previous); err != nil {
+					break
+				}
+			}
+			start := time.Now()
+			stats := measureDownload(ctx, config)
+			previous = time.Since(start)
+			if err := encoder.EncodeDownload(config.pause.writer(w), config, stats); err != nil {
+				return err
+			}
+			if err := logResults(config.Log, downloadRecord(time.Now(), stats)); err != nil {
+				return err
+			}
+			runs = append(runs, stats)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		speeds := make([]float64, len(runs))
+		for i, stats := range runs {
+			speeds[i] = stats.Speed
+		}
+		printRepeatSummary(summaryWriter(w, config.Format, config.Template), "download", summarizeRuns(speeds), config.Unit)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, stats := range runs {
+		if err := checkDownload(config, stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// measureDownload runs the single-stream phase, if any, followed by the
+// aggregate phase and returns the combined stats
+func measureDownload(ctx context.Context, config *DownloadConfig) DownloadStats {
+	var single DownloadStats
+	if config.SingleStream > 0 {
+		single = measureSingleStream(ctx, config)
+	}
+
+	aggregateConfig := *config
+	aggregateConfig.Duration = config.Duration - config.SingleStream
+	if config.MaxBytes > 0 {
+		// The cap covers both phases, so a single stream that used it all
+		// is the whole test
+		aggregateConfig.MaxBytes = config.MaxBytes - single.DataUsed
+		if aggregateConfig.MaxBytes <= 0 {
+			single.SingleStreamSpeed = single.Speed
+			single.DataCapped = true
+			return single
+		}
+	}
+	stats := measureDownloadSpeed(ctx, &aggregateConfig)
+	stats.SingleStreamSpeed = single.Speed
+	stats.DataUsed += single.DataUsed
+	stats.DataCapped = stats.DataCapped || single.DataCapped
+	return stats
+}
+
+// checkDownload returns the error for a run that received less than
+// --require-min-bytes or was slower than --min-download
+func checkDownload(config *DownloadConfig, stats DownloadStats) error {
+	if stats.BytesReceived < config.MinBytes {
+		return fmt.Errorf("%w: got %d bytes, need at least %d",
+			ErrInsufficientData, stats.BytesReceived, config.MinBytes)
+	}
+	if stats.Speed < config.MinSpeed {
+		return fmt.Errorf("%w: download %.2f Mbps, need at least %.2f Mbps",
+			ErrBelowMinSpeed, stats.Speed, config.MinSpeed)
+	}
+	return nil
+}
 
-	stats := measureDownloadSpeed(ctx, config)
-	printDownloadResults(stats)
+// selectFastestURL narrows the test files to the one whose host answers
+// fastest, for --auto-server. When no host answers, all files are kept.
+func selectFastestURL(ctx context.Context, config *DownloadConfig) error {
+	urls := config.URLs
+	if urls == nil {
+		urls = DefaultDownloadURLs
+	}
+	banner := bannerWriter(config.Format)
+	fmt.Fprintf(banner, "Measuring latency to %d test file hosts...\n", len(urls))
+	client := newSpeedClient(speedClientConfig{
+		RequireTLS13: config.RequireTLS13,
+		TLSCiphers:   config.TLSCiphers,
+		HTTPVersion:  config.HTTPVersion,
+		Insecure:     config.Insecure,
+		Proxy:        config.Proxy,
+	})
+	ranked, err := rankURLs(ctx, client, urls)
+	if err != nil {
+		return err
+	}
 
+	if config.Verbose {
+		for _, server := range ranked {
+			latency := "no reply"
+			if server.Ping.Received > 0 {
+				latency = fmt.Sprintf("%.1fms", float64(server.Ping.AvgRTT.Microseconds())/1000)
+			}
+			fmt.Fprintf(banner, "  %s: %s\n", server.URL, latency)
+		}
+	}
+
+	best := ranked[0]
+	if best.Ping.Received == 0 {
+		fmt.Fprintln(banner, "No test file host answered, using all of them")
+		return nil
+	}
+	config.URLs = []string{best.URL}
+	fmt.Fprintf(banner, "Auto-selected %s (%.1fms)\n", best.Name, float64(best.Ping.AvgRTT.Microseconds())/1000)
 	return nil
 }
 
+// measureSingleStream runs one worker alone for the single-stream part of the
+// test, exposing per-connection throughput that the aggregate hides
+func measureSingleStream(ctx context.Context, config *DownloadConfig) DownloadStats {
+	if config.Verbose {
+		fmt.Printf("Measuring single-stream speed for %v\n", config.SingleStream)
+	}
+
+	singleConfig := *config
+	singleConfig.Concurrency = 1
+	singleConfig.Duration = config.SingleStream
+	singleConfig.WatchRecovery = false
+	singleConfig.Bufferbloat = false
+	return measureDownloadSpeed(ctx, &singleConfig)
+}
+
 func measureDownloadSpeed(ctx context.Context, config *DownloadConfig) DownloadStats {
+	parent := ctx
+	var latency *LoadedLatency
+	if config.Bufferbloat {
+		target := latencyTarget(config.BufferbloatTarget, testFileOrder(config)[0])
+		latency = &LoadedLatency{Target: target, Idle: measureIdleLatency(ctx, target)}
+	}
+
 	var totalBytes int64
-	start := time.Now()
+	clock := clockOrReal(config.Clock)
+	window := newMeasureWindow(clock, config.pause)
+	elapsed := window.elapsed
+
+	// Bytes moved during the warmup are discarded and the window restarts
+	// when it ends, so slow start does not drag the speed down
+	var warmupDone <-chan time.Time
+	if config.Warmup > 0 {
+		warmupDone = clock.After(config.Warmup)
+	}
 
 	// Create channels for coordination
 	errChan := make(chan error, config.Concurrency)
-	bytesChan := make(chan int64, config.Concurrency)
+	bytesChan := make(chan workerBytes, config.Concurrency)
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(ctx, config.Duration)
+	// Create context with timeout; a zero duration means a single pass that
+	// ends when every worker has fetched its file once
+	var cancel context.CancelFunc
+	switch {
+	case config.Duration == 0:
+		ctx, cancel = context.WithCancel(ctx)
+	case config.pause != nil:
+		ctx, cancel = context.WithCancel(ctx)
+		go config.pause.expire(ctx, config.Duration, cancel)
+	default:
+		ctx, cancel = context.WithTimeout(ctx, config.Duration)
+	}
 	defer cancel()
 
+	// Sample per-interval throughput when watching for drops
+	var recoverySampler *sampler
+	if config.WatchRecovery {
+		recoverySampler = startSampler(ctx, clock, &totalBytes, recoverySampleInterval, config.pause)
+	}
+
+	if config.Seed != 0 {
+		seedRNG(config.Seed)
+	}
+	run := newDownloadRun(config)
+
+	var loadedLatency <-chan PingResult
+	if latency != nil {
+		loadedLatency = startLoadedLatency(ctx, latency.Target)
+	}
+
 	// Start concurrent downloads
 	var wg sync.WaitGroup
 	for i := 0; i < config.Concurrency; i++ {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			downloadWorker(ctx, workerID, config, bytesChan, errChan)
+			downloadWorker(ctx, workerID, run, bytesChan, errChan)
 		}(i)
 	}
 
 	// Start progress monitoring in separate goroutine
-	go func() {
-		if config.Verbose {
-			ticker := time.NewTicker(time.Second)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					current := atomic.LoadInt64(&totalBytes)
-					duration := time.Since(start)
-					speed := float64(current*8) / (1000 * 1000 * duration.Seconds()) // Mbps
-					fmt.Printf("\rCurrent speed: %.2f Mbps", speed)
-				}
-			}
-		}
-	}()
+	progress := startProgress(config.Verbose, "Current speed", &totalBytes, elapsed, config.Unit)
+	defer progress.Stop()
 
 	// Collect results
 	go func() {
@@ -102,70 +401,272 @@ func measureDownloadSpeed(ctx context.Context, config *DownloadConfig) DownloadS
 
 	// Process results
 	var lastError error
+	var used int64 // Bytes received including the warmup, for --max-bytes
+	capped := false
+	workerTotals := make([]int64, config.Concurrency)
+	noteError := func(err error) {
+		// Requests cut off by an interrupt are not failures, and keep a
+		// worker's fatal error over later transient ones
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if !errors.Is(lastError, ErrRetriesExhausted) || errors.Is(err, ErrRetriesExhausted) {
+			lastError = err
+		}
+	}
 	for {
 		select {
+		case <-warmupDone:
+			warmupDone = nil
+			window.restart()
+			if config.Verbose {
+				fmt.Println("\nWarmup finished, measuring")
+			}
+
 		case bytes, ok := <-bytesChan:
 			if !ok {
-				duration := time.Since(start)
-				return DownloadStats{
-					BytesReceived: totalBytes,
+				progress.Stop()
+				// Workers may have reported errors just before exiting
+				for err := range errChan {
+					noteError(err)
+				}
+
+				// The samplers keep reading the counter until the context
+				// ends, so read it atomically like they do
+				transferred := atomic.LoadInt64(&totalBytes)
+				duration := elapsed()
+				stats := DownloadStats{
+					BytesReceived: transferred,
 					Duration:      duration,
-					Speed:         float64(totalBytes*8) / (1000 * 1000 * duration.Seconds()),
+					Speed:         mbps(transferred, duration),
 					Error:         lastError,
+					Protocols:     run.protocols.list(),
+					RateLimited:   atomic.LoadInt64(&run.rateLimited),
+					Phases:        run.phases.snapshot(),
+					CacheHits:     atomic.LoadInt64(&run.cacheHits),
+					CacheReported: atomic.LoadInt64(&run.cacheReported),
+					Workers:       workerBreakdown(workerTotals, duration),
+					DataUsed:      used,
+					DataCapped:    capped,
+					Cancelled:     parent.Err() != nil,
 				}
+				// A single pass that was not cut short ends when the last file
+				// does, without the context expiring
+				finished := config.Duration == 0 && ctx.Err() == nil
+				cancel()
+				if recoverySampler != nil {
+					stats.Recoveries = detectRecoveries(recoverySampler.Samples(), finished)
+				}
+				if latency != nil {
+					latency.Loaded = <-loadedLatency
+					stats.Latency = latency
+				}
+				return stats
+			}
+			if warmupDone == nil {
+				atomic.AddInt64(&totalBytes, bytes.n)
+				workerTotals[bytes.worker] += bytes.n
+			}
+
+			// Stop at the data cap; the workers exit after their current read
+			used += bytes.n
+			if config.MaxBytes > 0 && used >= config.MaxBytes && !capped {
+				capped = true
+				cancel()
 			}
-			atomic.AddInt64(&totalBytes, bytes)
 
 		case err := <-errChan:
 			if err != nil {
-				lastError = err
+				noteError(err)
+
+				// Give up early instead of reporting 0 Mbps for the whole
+				// duration when no server could be reached at all
+				if atomic.LoadInt64(&totalBytes) == 0 &&
+					atomic.LoadInt64(&run.unreachable) == int64(config.Concurrency) {
+					lastError = fmt.Errorf("%w: %w", ErrNoServersReachable, err)
+					cancel()
+				}
 			}
 		}
 	}
 }
 
-func downloadWorker(ctx context.Context, id int, config *DownloadConfig,
-	bytesChan chan<- int64, errChan chan<- error) {
+// downloadRun holds the state shared by all workers of one measurement
+type downloadRun struct {
+	config      *DownloadConfig
+	client      *http.Client // Shared by all workers
+	urls        []string
+	protocols   *protocolSet
+	phases      phaseRecorder
+	rateLimited int64
+	unreachable int64 // Workers whose first request failed
 
-	for {
+	cacheHits, cacheReported int64
+}
+
+func newDownloadRun(config *DownloadConfig) *downloadRun {
+	return &downloadRun{
+		config: config,
+		client: newSpeedClient(speedClientConfig{
+			RequireTLS13: config.RequireTLS13,
+			TLSCiphers:   config.TLSCiphers,
+			HTTPVersion:  config.HTTPVersion,
+			Insecure:     config.Insecure,
+			Proxy:        config.Proxy,
+		}),
+		urls:      testFileOrder(config),
+		phases:    phaseRecorder{clock: config.Clock},
+		protocols: &protocolSet{},
+	}
+}
+
+// testFileOrder returns the test files in the order workers are assigned to
+// them. Without a seed this is the built-in order; with one it is a
+// permutation that is stable across runs using the same seed.
+func testFileOrder(config *DownloadConfig) []string {
+	files := config.URLs
+	if files == nil {
+		files = DefaultDownloadURLs
+	}
+	if config.Seed == 0 {
+		return files
+	}
+
+	urls := make([]string, len(files))
+	for i, j := range randPerm(len(files)) {
+		urls[i] = files[j]
+	}
+	return urls
+}
+
+func downloadWorker(ctx context.Context, id int, run *downloadRun,
+	bytesChan chan<- workerBytes, errChan chan<- error) {
+
+	file := id     // Index into run.urls, advanced when a file keeps failing
+	failures := 0  // Consecutive failures on the current file
+	abandoned := 0 // Files given up on since the last success
+	for attempt := 0; ; attempt++ {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			// Choose a random test file
-			url := defaultTestFiles[id%len(defaultTestFiles)]
+			if err := run.config.pause.wait(ctx); err != nil {
+				return
+			}
+			url := run.urls[file%len(run.urls)]
+
+			err := downloadChunk(ctx, run, id, url, bytesChan)
+			backoff, limited := backoffFor(err, retryBackoff(failures+1, initialRetryBackoff, maxRetryBackoff))
+			if attempt == 0 && err != nil && !limited && ctx.Err() == nil {
+				atomic.AddInt64(&run.unreachable, 1)
+			}
 
-			if err := downloadChunk(ctx, url, bytesChan); err != nil {
+			if run.config.Duration == 0 {
+				// Single pass: each worker fetches its file exactly once
+				if err != nil {
+					errChan <- fmt.Errorf("worker %d error: %w", id, err)
+				}
+				return
+			}
+
+			if err != nil {
 				errChan <- fmt.Errorf("worker %d error: %w", id, err)
-				time.Sleep(time.Second) // Back off on error
+
+				// A 429 is the server pacing us, not a failure to give up on
+				if limited {
+					atomic.AddInt64(&run.rateLimited, 1)
+				} else {
+					failures++
+				}
+
+				if limit := run.config.MaxRetries; limit > 0 && failures > limit && ctx.Err() == nil {
+					abandoned++
+					if abandoned >= len(run.urls) {
+						errChan <- fmt.Errorf("worker %d: %w (%d retries each): %w", id, ErrRetriesExhausted, limit, err)
+						return
+					}
+					// Move on to the next file right away; its server may be fine
+					file++
+					failures = 0
+					continue
+				}
+
+				// Back off on error, or as long as a 429 asked for
+				if err := sleepCtx(ctx, backoff); err != nil {
+					return
+				}
 				continue
 			}
+			failures, abandoned = 0, 0
 		}
 	}
 }
 
-func downloadChunk(ctx context.Context, url string, bytesChan chan<- int64) error {
+func downloadChunk(ctx context.Context, run *downloadRun, worker int, url string, bytesChan chan<- workerBytes) error {
+	if run.config.CacheBust {
+		url = cacheBustURL(url)
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
+	if run.config.CacheBust {
+		req.Header.Set("Cache-Control", "no-cache")
+	}
+
+	req, finish := run.phases.trace(req)
+	defer finish()
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := run.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("making request: %w", err)
+		return fmt.Errorf("making request: %w", wrapProxyError(wrapTLSError(err, run.config.RequireTLS13, len(run.config.TLSCiphers) > 0), run.config.Proxy))
 	}
 	defer resp.Body.Close()
 
+	if proto := describeProtocol(resp); run.protocols.add(proto) && run.config.Verbose {
+		fmt.Printf("\nNegotiated %s (%s)\n", resp.Proto, proto)
+	}
+	if run.config.Verbose {
+		logResponse(url, resp)
+	}
+	if hit, reported := cacheStatus(resp); reported {
+		atomic.AddInt64(&run.cacheReported, 1)
+		if hit {
+			atomic.AddInt64(&run.cacheHits, 1)
+		}
+	}
+
+	if err := checkRateLimit(resp, time.Second); err != nil {
+		return err
+	}
+	// An error page is not test data, and counts as a failed attempt
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// The transport aborts a blocked Read as soon as ctx is done, so a stalled
+	// server cannot hold the worker past the test duration or Ctrl-C. That
+	// end of the test is not a transfer error.
 	buf := make([]byte, 32*1024) // 32KB buffer
-	for {
+	for ctx.Err() == nil {
 		n, err := resp.Body.Read(buf)
 		if n > 0 {
-			bytesChan <- int64(n)
+			bytesChan <- workerBytes{worker: worker, n: int64(n)}
 		}
 		if err == io.EOF {
 			break
 		}
+		if err != nil && ctx.Err() != nil {
+			break
+		}
 		if err != nil {
+			// HTTP/1.0 servers delimit the body by closing the connection, and
+			// some of them close before the advertised Content-Length. The
+			// bytes already read were really transferred, so treat this as
+			// the end of the response rather than a worker error.
+			if isHTTP10(resp) && errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
 			return fmt.Errorf("reading response: %w", err)
 		}
 	}
@@ -173,27 +674,204 @@ func downloadChunk(ctx context.Context, url string, bytesChan chan<- int64) erro
 	return nil
 }
 
+// logResponse shows which server answered a transfer and how, including
+// where a redirect ended up, so users can tell which CDN they hit
+func logResponse(requested string, resp *http.Response) {
+	length := "unknown length"
+	if resp.ContentLength >= 0 {
+		length = fmt.Sprintf("%.2f MB", float64(resp.ContentLength)/(1024*1024))
+	}
+	server := resp.Header.Get("Server")
+	if server == "" {
+		server = "not reported"
+	}
+
+	fmt.Printf("\nGET %s: %s, %s, %s, server %s\n", requested, resp.Status, resp.Proto, length, server)
+	if final := resp.Request.URL.String(); final != requested {
+		fmt.Printf("  redirected to %s\n", final)
+	}
+}
+
+func isHTTP10(resp *http.Response) bool {
+	return resp.ProtoMajor == 1 && resp.ProtoMinor == 0
+}
+
 func parseDownloadConfig(args []string) (*DownloadConfig, error) {
 	cmd := commands.DownloadCmd
-	if err := cmd.Parse(args); err != nil {
+	err := cmd.Parse(args)
+	if err != nil {
 		return nil, fmt.Errorf("parsing arguments: %w", err)
 	}
+	if err := applyFlagSources(cmd); err != nil {
+		return nil, err
+	}
+	return downloadConfigFromFlags(cmd)
+}
 
-	return &DownloadConfig{
-		Duration:    cmd.Lookup("duration").Value.(flag.Getter).Get().(time.Duration),
-		Concurrency: cmd.Lookup("concurrency").Value.(flag.Getter).Get().(int),
-		Verbose:     cmd.Lookup("verbose").Value.(flag.Getter).Get().(bool),
-	}, nil
+// downloadConfigFromFlags builds and validates the configuration from the
+// parsed flags of a download flag set
+func downloadConfigFromFlags(cmd *flag.FlagSet) (*DownloadConfig, error) {
+	config := &DownloadConfig{
+		Duration:      cmd.Lookup("duration").Value.(flag.Getter).Get().(time.Duration),
+		Concurrency:   cmd.Lookup("concurrency").Value.(flag.Getter).Get().(int),
+		Verbose:       cmd.Lookup("verbose").Value.(flag.Getter).Get().(bool),
+		WatchRecovery: cmd.Lookup("watch-recovery").Value.(flag.Getter).Get().(bool),
+		Seed:          cmd.Lookup("seed").Value.(flag.Getter).Get().(int64),
+		SingleStream:  cmd.Lookup("single-stream").Value.(flag.Getter).Get().(time.Duration),
+		Warmup:        cmd.Lookup("warmup").Value.(flag.Getter).Get().(time.Duration),
+		RequireTLS13:  cmd.Lookup("require-tls13").Value.(flag.Getter).Get().(bool),
+		Insecure:      cmd.Lookup("insecure").Value.(flag.Getter).Get().(bool),
+		Format:        cmd.Lookup("format").Value.String(),
+		Template:      cmd.Lookup("template").Value.String(),
+		Out:           cmd.Lookup("out").Value.String(),
+		CacheBust:     cmd.Lookup("cache-bust").Value.(flag.Getter).Get().(bool),
+		MaxRetries:    cmd.Lookup("max-retries").Value.(flag.Getter).Get().(int),
+		Server:        cmd.Lookup("server").Value.(flag.Getter).Get().(int),
+		ServerList:    cmd.Lookup("server-list").Value.String(),
+		AutoServer:    cmd.Lookup("auto-server").Value.(flag.Getter).Get().(bool),
+		MinSpeed:      cmd.Lookup("min-download").Value.(flag.Getter).Get().(float64),
+		Repeat:        cmd.Lookup("repeat").Value.(flag.Getter).Get().(int),
+		Interval:      cmd.Lookup("interval").Value.(flag.Getter).Get().(time.Duration),
+		Log:           cmd.Lookup("log").Value.String(),
+
+		Bufferbloat:       cmd.Lookup("bufferbloat").Value.(flag.Getter).Get().(bool),
+		BufferbloatTarget: cmd.Lookup("bufferbloat-target").Value.String(),
+	}
+	if config.AutoServer && config.Server != 0 {
+		return nil, errors.New("--auto-server and --server cannot be used together")
+	}
+	if config.ServerList == "" {
+		config.ServerList = DefaultServerListURL
+	}
+
+	if config.Concurrency < 1 {
+		return nil, fmt.Errorf("invalid --concurrency %d (expected at least 1 stream)", config.Concurrency)
+	}
+	if config.Duration < 0 {
+		return nil, fmt.Errorf("invalid --duration %v (expected 0 for a single pass, or a positive duration)", config.Duration)
+	}
+	if config.MaxRetries < 0 {
+		return nil, errors.New("--max-retries cannot be negative")
+	}
+	if config.MinSpeed < 0 {
+		return nil, errors.New("--min-download cannot be negative")
+	}
+	if err := parseRepeat(config.Repeat, config.Interval, config.Format); err != nil {
+		return nil, err
+	}
+
+	var err error
+	config.Unit, err = parseSpeedUnit(cmd.Lookup("unit").Value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	config.HTTPVersion, err = parseHTTPVersion(cmd.Lookup("http").Value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	config.Proxy, err = parseProxyURL(cmd.Lookup("proxy").Value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	config.TLSCiphers, err = parseCipherSuites(cmd.Lookup("tls-ciphers").Value.String(), config.RequireTLS13)
+	if err != nil {
+		return nil, fmt.Errorf("parsing --tls-ciphers: %w", err)
+	}
+
+	config.MaxBytes, err = parseMaxBytes(cmd.Lookup("max-bytes").Value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if minBytes := cmd.Lookup("require-min-bytes").Value.String(); minBytes != "" {
+		config.MinBytes, err = parseByteSize(minBytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing --require-min-bytes: %w", err)
+		}
+	}
+
+	if config.SingleStream < 0 || (config.SingleStream > 0 && config.SingleStream >= config.Duration) {
+		return nil, fmt.Errorf("single-stream phase (%v) must be between 0 and the test duration (%v)",
+			config.SingleStream, config.Duration)
+	}
+
+	if config.Warmup < 0 {
+		return nil, errors.New("--warmup cannot be negative")
+	}
+	if config.Warmup > 0 {
+		if config.Duration == 0 {
+			return nil, errors.New("--warmup needs a --duration; single-pass runs have no time to exclude")
+		}
+		if config.Duration-config.SingleStream <= config.Warmup {
+			return nil, fmt.Errorf("--warmup (%v) leaves no measurement time in the %v test",
+				config.Warmup, config.Duration-config.SingleStream)
+		}
+		if config.SingleStream > 0 && config.SingleStream <= config.Warmup {
+			return nil, fmt.Errorf("--warmup (%v) leaves no measurement time in the %v single-stream phase",
+				config.Warmup, config.SingleStream)
+		}
+	}
+
+	return config, nil
 }
 
-func printDownloadResults(stats DownloadStats) {
-	fmt.Printf("\n\nDOWNLOAD TEST RESULTS\n")
-	fmt.Println(strings.Repeat("=", 50))
-	fmt.Printf("Total data received: %.2f MB\n", float64(stats.BytesReceived)/(1024*1024))
-	fmt.Printf("Test duration: %.1f seconds\n", stats.Duration.Seconds())
-	fmt.Printf("Average speed: %.2f Mbps\n", stats.Speed)
+func printDownloadResults(w io.Writer, config *DownloadConfig, stats DownloadStats) {
+	fmt.Fprintf(w, "\n\nDOWNLOAD TEST RESULTS\n")
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+	fmt.Fprintf(w, "Total data received: %.2f MB\n", float64(stats.BytesReceived)/(1024*1024))
+	fmt.Fprintf(w, "Test duration: %.1f seconds\n", stats.Duration.Seconds())
+	fmt.Fprintf(w, "Average speed: %s\n", speedText(stats.Speed, stats.Duration, config.Unit))
+	if config.SingleStream > 0 {
+		fmt.Fprintf(w, "Single-stream speed: %s\n", formatSpeed(stats.SingleStreamSpeed, config.Unit))
+	}
+	if len(stats.Protocols) > 0 {
+		fmt.Fprintf(w, "Protocol: %s\n", strings.Join(stats.Protocols, ", "))
+	}
+	printRequestPhases(w, stats.Phases)
+	if stats.Latency != nil {
+		printLoadedLatency(w, stats.Latency)
+	}
+	if stats.CacheReported > 0 {
+		fmt.Fprintf(w, "Cache hits: %d of %d responses with cache headers\n", stats.CacheHits, stats.CacheReported)
+	}
+	if stats.RateLimited > 0 {
+		fmt.Fprintf(w, "Rate limited: %d times\n", stats.RateLimited)
+	}
+	if config.MaxBytes > 0 {
+		printDataUsed(w, stats.DataUsed, config.MaxBytes, stats.DataCapped)
+	}
+	if stats.Cancelled {
+		fmt.Fprintf(w, "Interrupted after %.1f seconds, results are partial\n", stats.Duration.Seconds())
+	}
 	if stats.Error != nil {
-		fmt.Printf("Errors encountered: %v\n", stats.Error)
+		fmt.Fprintf(w, "Errors encountered: %v\n", stats.Error)
+	}
+	if config.WatchRecovery {
+		printRecoveries(w, stats.Recoveries)
+	}
+	if config.Verbose {
+		printWorkerStats(w, stats.Workers, config.Unit)
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+}
+
+func printRecoveries(w io.Writer, events []RecoveryEvent) {
+	if len(events) == 0 {
+		fmt.Fprintln(w, "Throughput drops: none detected")
+		return
+	}
+
+	fmt.Fprintf(w, "Throughput drops: %d\n", len(events))
+	for _, event := range events {
+		if event.Recovered {
+			fmt.Fprintf(w, "  - dropped at %.1fs, recovered after %.1fs\n",
+				event.DroppedAt.Seconds(), event.RecoveredAfter.Seconds())
+		} else {
+			fmt.Fprintf(w, "  - dropped at %.1fs, not recovered after %.1fs\n",
+				event.DroppedAt.Seconds(), event.RecoveredAfter.Seconds())
+		}
 	}
-	fmt.Println(strings.Repeat("=", 50))
 }
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"speedgo/commands"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestFileServer serves a payload of size bytes to every GET, after
+// failing with status for requests numbered in failFrom and later (1-based,
+// 0 never fails)
+func newTestFileServer(t *testing.T, size int, status int, failFrom int64) *httptest.Server {
+	t.Helper()
+	var requests int64
+	payload := strings.Repeat("x", size)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := atomic.AddInt64(&requests, 1); failFrom > 0 && n >= failFrom {
+			http.Error(w, strings.Repeat("error page ", 100), status)
+			return
+		}
+		w.Write([]byte(payload))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownloadServerErrorIsNotThroughput(t *testing.T) {
+	server := newTestFileServer(t, 1024, http.StatusInternalServerError, 1)
+
+	stats := measureDownloadSpeed(context.Background(), &DownloadConfig{
+		URLs:        []string{server.URL},
+		Duration:    5 * time.Second,
+		Concurrency: 2,
+	})
+
+	if stats.BytesReceived != 0 {
+		t.Errorf("BytesReceived = %d, want 0 from error pages", stats.BytesReceived)
+	}
+	if !errors.Is(stats.Error, ErrNoServersReachable) {
+		t.Errorf("Error = %v, want %v", stats.Error, ErrNoServersReachable)
+	}
+	if !strings.Contains(errorString(stats.Error), "500") {
+		t.Errorf("Error = %v, want it to name the status", stats.Error)
+	}
+}
+
+func TestDownloadGivesUpAfterMaxRetries(t *testing.T) {
+	// The first request succeeds so the server counts as reachable
+	server := newTestFileServer(t, 1024, http.StatusInternalServerError, 2)
+
+	start := time.Now()
+	stats := measureDownloadSpeed(context.Background(), &DownloadConfig{
+		URLs:        []string{server.URL},
+		Duration:    30 * time.Second,
+		Concurrency: 1,
+		MaxRetries:  1,
+	})
+
+	if !errors.Is(stats.Error, ErrRetriesExhausted) {
+		t.Fatalf("Error = %v, want %v", stats.Error, ErrRetriesExhausted)
+	}
+	if stats.BytesReceived != 1024 {
+		t.Errorf("BytesReceived = %d, want only the 1024 bytes of the good response", stats.BytesReceived)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("worker gave up after %v, want well before the 30s duration", elapsed)
+	}
+}
+
+func TestDownloadStopsOnStalledServer(t *testing.T) {
+	// The server sends a little and then stalls until the client goes away
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1048576")
+		w.Write([]byte(strings.Repeat("x", 4096)))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		interrupt     time.Duration // Cancel the parent context after this, 0 never
+		wantCancelled bool
+	}{
+		{"duration ends", 0, false},
+		{"interrupted", 100 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.interrupt > 0 {
+				time.AfterFunc(tt.interrupt, cancel)
+			}
+
+			start := time.Now()
+			stats := measureDownloadSpeed(ctx, &DownloadConfig{
+				URLs:        []string{server.URL},
+				Duration:    300 * time.Millisecond,
+				Concurrency: 2,
+			})
+
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("returned after %v, want promptly after the 300ms duration", elapsed)
+			}
+			if stats.Error != nil {
+				t.Errorf("Error = %v, want none for a read cut off by the end of the test", stats.Error)
+			}
+			if stats.BytesReceived == 0 {
+				t.Error("BytesReceived = 0, want the bytes sent before the stall")
+			}
+			if stats.Cancelled != tt.wantCancelled {
+				t.Errorf("Cancelled = %v, want %v", stats.Cancelled, tt.wantCancelled)
+			}
+		})
+	}
+}
+
+func TestParseDownloadConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"single pass", []string{"--duration=0"}, ""},
+		{"zero concurrency", []string{"--concurrency=0"}, "invalid --concurrency 0"},
+		{"negative concurrency", []string{"--concurrency=-2"}, "invalid --concurrency -2"},
+		{"negative duration", []string{"--duration=-5s"}, "invalid --duration -5s"},
+		{"negative retries", []string{"--max-retries=-1"}, "--max-retries cannot be negative"},
+		{"warmup", []string{"--duration=10s", "--warmup=2s"}, ""},
+		{"negative warmup", []string{"--warmup=-1s"}, "--warmup cannot be negative"},
+		{"warmup in a single pass", []string{"--duration=0", "--warmup=1s"}, "--warmup needs a --duration"},
+		{"warmup as long as the test", []string{"--duration=5s", "--warmup=5s"}, "no measurement time in the 5s test"},
+		{"warmup past the aggregate phase", []string{"--duration=10s", "--single-stream=3s", "--warmup=8s"}, "no measurement time in the 7s test"},
+		{"warmup as long as the single stream", []string{"--duration=10s", "--single-stream=2s", "--warmup=2s"}, "no measurement time in the 2s single-stream phase"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(t, commands.DownloadCmd)
+			_, err := parseDownloadConfig(tt.args)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("parseDownloadConfig(%q): %v", tt.args, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseDownloadConfig(%q) error = %v, want one containing %q", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloadHTTP10CloseDelimited(t *testing.T) {
+	const body = 8192
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"no content length", "HTTP/1.0 200 OK\r\n\r\n"},
+		{"closed before content length", "HTTP/1.0 200 OK\r\nContent-Length: 16384\r\n\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The server delimits the body by closing the connection
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer conn.Close()
+				buf.WriteString(tt.header)
+				buf.WriteString(strings.Repeat("x", body))
+				buf.Flush()
+			}))
+			defer server.Close()
+
+			stats := measureDownloadSpeed(context.Background(), &DownloadConfig{
+				URLs:        []string{server.URL},
+				Duration:    0, // Fetch the file once
+				Concurrency: 1,
+			})
+
+			if stats.BytesReceived != body {
+				t.Errorf("BytesReceived = %d, want the %d bytes sent", stats.BytesReceived, body)
+			}
+			if stats.Error != nil {
+				t.Errorf("Error = %v, want none for a body ended by the connection closing", stats.Error)
+			}
+		})
+	}
+}
+
+func TestCheckDownload(t *testing.T) {
+	tests := []struct {
+		name     string
+		minBytes int64
+		minSpeed float64
+		stats    DownloadStats
+		want     error
+	}{
+		{"no minimum", 0, 0, DownloadStats{}, nil},
+		{"slow", 0, 100, DownloadStats{BytesReceived: 1 << 20, Speed: 99.9}, ErrBelowMinSpeed},
+		{"at the minimum", 0, 100, DownloadStats{BytesReceived: 1 << 20, Speed: 100}, nil},
+		{"fast", 0, 100, DownloadStats{BytesReceived: 1 << 20, Speed: 250}, nil},
+		{"too little data", 2 << 20, 0, DownloadStats{BytesReceived: 1 << 20, Speed: 250}, ErrInsufficientData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &DownloadConfig{MinBytes: tt.minBytes, MinSpeed: tt.minSpeed}
+			err := checkDownload(config, tt.stats)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("checkDownload() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferFailingAtOnceIsZeroMbps(t *testing.T) {
+	server := newTestFileServer(t, 1024, http.StatusServiceUnavailable, 1)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name  string
+		speed func() float64
+	}{
+		{"single-pass download", func() float64 {
+			return measureDownload(context.Background(), &DownloadConfig{URLs: []string{server.URL}, Concurrency: 2}).Speed
+		}},
+		{"download interrupted before starting", func() float64 {
+			return measureDownload(cancelled, &DownloadConfig{URLs: []string{server.URL}, Duration: time.Second, Concurrency: 2}).Speed
+		}},
+		{"upload interrupted before starting", func() float64 {
+			return measureUploadSpeed(cancelled, &UploadConfig{URL: server.URL, Duration: time.Second, Concurrency: 2, ChunkSize: 1024}).Speed
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if speed := tt.speed(); speed != 0 {
+				t.Errorf("Speed = %v, want 0 for a run that moved no data", speed)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Package core env.go
+package core
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix starts the environment variable of every flag
+const envPrefix = "SPEEDGO_"
+
+// applyFlagSources fills in the flags of cmd that were not given on the
+// command line, first from the environment and then from the --config file,
+// so values resolve as defaults < file < environment < flags
+func applyFlagSources(cmd *flag.FlagSet) error {
+	set := make(map[string]bool)
+	cmd.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if err := applyEnv(cmd, set); err != nil {
+		return err
+	}
+	return applyConfigFile(cmd, set)
+}
+
+// envKey returns the variable that sets a flag of a command, e.g.
+// SPEEDGO_PING_TARGETS or SPEEDGO_DOWNLOAD_MAX_RETRIES
+func envKey(command, name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(command+"_"+name, "-", "_"))
+}
+
+// applyEnv sets each flag not in set from its environment variable, if
+// present, and adds it to set
+func applyEnv(cmd *flag.FlagSet, set map[string]bool) error {
+	var err error
+	cmd.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		key := envKey(cmd.Name(), f.Name)
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			return
+		}
+		if setErr := cmd.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("environment variable %s: invalid value %q: %w", key, value, setErr)
+			return
+		}
+		set[f.Name] = true
+	})
+	return err
+}
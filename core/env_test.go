@@ -0,0 +1,107 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvKey(t *testing.T) {
+	tests := []struct {
+		command, name, want string
+	}{
+		{"ping", "targets", "SPEEDGO_PING_TARGETS"},
+		{"download", "max-retries", "SPEEDGO_DOWNLOAD_MAX_RETRIES"},
+		{"test", "bidirectional", "SPEEDGO_TEST_BIDIRECTIONAL"},
+	}
+	for _, tt := range tests {
+		if got := envKey(tt.command, tt.name); got != tt.want {
+			t.Errorf("envKey(%q, %q) = %q, want %q", tt.command, tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestApplyFlagSourcesPrecedence checks that values resolve as
+// defaults < config file < environment < command line
+func TestApplyFlagSourcesPrecedence(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          string
+		configFromEnv bool // Name the file in SPEEDGO_PING_CONFIG instead of --config
+		env           map[string]string
+		args          []string
+		want          string // --count after all sources are applied
+		wantErr       string
+	}{
+		{name: "default", want: "4"},
+		{name: "file over default", file: `{"count": 10}`, want: "10"},
+		{name: "environment over default", env: map[string]string{"SPEEDGO_PING_COUNT": "20"}, want: "20"},
+		{
+			name: "environment over file",
+			file: `{"count": 10}`,
+			env:  map[string]string{"SPEEDGO_PING_COUNT": "20"},
+			want: "20",
+		},
+		{
+			name: "command line over everything",
+			file: `{"count": 10}`,
+			env:  map[string]string{"SPEEDGO_PING_COUNT": "20"},
+			args: []string{"--count=30"},
+			want: "30",
+		},
+		{
+			name: "other commands' variables ignored",
+			env:  map[string]string{"SPEEDGO_DOWNLOAD_COUNT": "20"},
+			want: "4",
+		},
+		{
+			name:    "invalid environment value",
+			env:     map[string]string{"SPEEDGO_PING_COUNT": "many"},
+			wantErr: `environment variable SPEEDGO_PING_COUNT: invalid value "many"`,
+		},
+		{
+			name:          "config file named by the environment",
+			file:          `{"count": 10}`,
+			configFromEnv: true,
+			want:          "10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "speedgo.json")
+				if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if tt.configFromEnv {
+					t.Setenv("SPEEDGO_PING_CONFIG", path)
+				} else {
+					args = append([]string{"--config=" + path}, args...)
+				}
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cmd := newSourcesTestCmd()
+			if err := cmd.Parse(args); err != nil {
+				t.Fatal(err)
+			}
+			err := applyFlagSources(cmd)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cmd.Lookup("count").Value.String(); got != tt.want {
+				t.Errorf("--count = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+// Package core format.go
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Encoder renders the results of a command in one output format
+type Encoder interface {
+	EncodePing(w io.Writer, config *PingConfig, results []PingResult) error
+	EncodeDownload(w io.Writer, config *DownloadConfig, stats DownloadStats) error
+	EncodeUpload(w io.Writer, config *UploadConfig, stats UploadStats) error
+}
+
+// newEncoder returns the encoder for a --format value, or for the
+// --template file when one is given
+func newEncoder(format, templatePath string) (Encoder, error) {
+	if templatePath != "" {
+		return newTemplateEncoder(templatePath)
+	}
+
+	switch format {
+	case "", "table":
+		return tableEncoder{}, nil
+	case "markdown", "md":
+		return markdownEncoder{}, nil
+	case "json":
+		return jsonEncoder{}, nil
+	case "prometheus":
+		return prometheusEncoder{}, nil
+	case "csv":
+		return csvEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (expected table, markdown, json, csv or prometheus)", format)
+	}
+}
+
+// bannerWriter returns where start-of-test messages go: stdout, except for
+// machine-readable output, which must stay parseable when results go to
+// stdout too
+func bannerWriter(format string) io.Writer {
+	switch format {
+	case "json", "csv", "prometheus":
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// openOutput resolves an --out value to the writer results are sent to:
+// stdout for "" or "-", stderr for "stderr", otherwise the named file,
+// which is created or truncated. The returned function closes any file.
+func openOutput(dest string) (io.Writer, func() error, error) {
+	switch dest {
+	case "", "-":
+		return os.Stdout, func() error { return nil }, nil
+	case "stderr":
+		return os.Stderr, func() error { return nil }, nil
+	}
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening output file: %w", err)
+	}
+	return file, file.Close, nil
+}
+
+// writeResults opens the --out destination and runs encode against it
+func writeResults(dest string, encode func(w io.Writer) error) error {
+	w, closeOutput, err := openOutput(dest)
+	if err != nil {
+		return err
+	}
+	if err := encode(w); err != nil {
+		closeOutput()
+		return err
+	}
+	return closeOutput()
+}
+
+// tableEncoder is the default human-readable fixed-width output
+type tableEncoder struct{}
+
+func (tableEncoder) EncodePing(w io.Writer, config *PingConfig, results []PingResult) error {
+	if config.Compact {
+		printCompactResults(w, results, config.Color)
+		return nil
+	}
+	printResults(w, results, config.Color)
+	return nil
+}
+
+func (tableEncoder) EncodeDownload(w io.Writer, config *DownloadConfig, stats DownloadStats) error {
+	printDownloadResults(w, config, stats)
+	return nil
+}
+
+func (tableEncoder) EncodeUpload(w io.Writer, config *UploadConfig, stats UploadStats) error {
+	printUploadResults(w, config, stats)
+	return nil
+}
+
+// markdownEncoder renders GitHub-flavored Markdown tables for pasting into
+// issues and wikis
+type markdownEncoder struct{}
+
+func (markdownEncoder) EncodePing(w io.Writer, config *PingConfig, results []PingResult) error {
+	fmt.Fprintln(w, "| Status | Target | Min | Avg | Max | P95 | Jitter | Loss |")
+	fmt.Fprintln(w, "| --- | --- | ---: | ---: | ---: | ---: | ---: | ---: |")
+	var notes []string
+	for _, result := range results {
+		status := classifyResult(result)
+		if result.Cancelled {
+			notes = append(notes, fmt.Sprintf("%s was cut short after %d probes",
+				escapeMarkdown(result.label()), result.Sent))
+		}
+		if result.PrivateAddress {
+			notes = append(notes, fmt.Sprintf("Warning: %s resolved to private/bogon address %s",
+				escapeMarkdown(result.Target), result.Address))
+		}
+		if result.Received == 0 {
+			fmt.Fprintf(w, "| %s %s | %s | N/A | N/A | N/A | N/A | N/A | 100%% |\n",
+				status.Glyph(), status, escapeMarkdown(result.label()))
+			continue
+		}
+
+		lossPercent := result.lossPercent()
+		fmt.Fprintf(w, "| %s %s | %s | %.1fms | %.1fms | %.1fms | %.1fms | %.1fms | %.1f%% |\n",
+			status.Glyph(), status, escapeMarkdown(result.label()),
+			float64(result.MinRTT.Microseconds())/1000,
+			float64(result.AvgRTT.Microseconds())/1000,
+			float64(result.MaxRTT.Microseconds())/1000,
+			float64(result.Percentile(95).Microseconds())/1000,
+			float64(result.Jitter.Microseconds())/1000,
+			lossPercent)
+	}
+
+	for _, note := range notes {
+		fmt.Fprintf(w, "\n%s\n", note)
+	}
+	return nil
+}
+
+func (markdownEncoder) EncodeDownload(w io.Writer, config *DownloadConfig, stats DownloadStats) error {
+	fmt.Fprintln(w, "| Download | Value |")
+	fmt.Fprintln(w, "| --- | ---: |")
+	fmt.Fprintf(w, "| Data received | %.2f MB |\n", float64(stats.BytesReceived)/(1024*1024))
+	fmt.Fprintf(w, "| Duration | %.1f s |\n", stats.Duration.Seconds())
+	fmt.Fprintf(w, "| Average speed | %s |\n", speedText(stats.Speed, stats.Duration, config.Unit))
+	if config.SingleStream > 0 {
+		fmt.Fprintf(w, "| Single-stream speed | %s |\n", formatSpeed(stats.SingleStreamSpeed, config.Unit))
+	}
+	if len(stats.Protocols) > 0 {
+		fmt.Fprintf(w, "| Protocol | %s |\n", escapeMarkdown(strings.Join(stats.Protocols, ", ")))
+	}
+	if stats.Latency != nil {
+		writeLatencyMarkdown(w, stats.Latency)
+	}
+	if stats.CacheReported > 0 {
+		fmt.Fprintf(w, "| Cache hits | %d of %d |\n", stats.CacheHits, stats.CacheReported)
+	}
+	if config.MaxBytes > 0 {
+		writeDataUsedMarkdown(w, stats.DataUsed, config.MaxBytes, stats.DataCapped)
+	}
+	if stats.Cancelled {
+		fmt.Fprintln(w, "| Interrupted | yes, results are partial |")
+	}
+	if stats.Error != nil {
+		fmt.Fprintf(w, "| Last error | %s |\n", escapeMarkdown(stats.Error.Error()))
+	}
+	return nil
+}
+
+func (markdownEncoder) EncodeUpload(w io.Writer, config *UploadConfig, stats UploadStats) error {
+	fmt.Fprintln(w, "| Upload | Value |")
+	fmt.Fprintln(w, "| --- | ---: |")
+	if config.File != "" {
+		fmt.Fprintf(w, "| Payload file | %s |\n", escapeMarkdown(config.File))
+	}
+	fmt.Fprintf(w, "| Data sent | %.2f MB |\n", float64(stats.BytesSent)/(1024*1024))
+	if config.Compress {
+		fmt.Fprintf(w, "| Uncompressed payload | %.2f MB |\n", float64(stats.LogicalBytes)/(1024*1024))
+	}
+	if stats.Latency != nil {
+		writeLatencyMarkdown(w, stats.Latency)
+	}
+	fmt.Fprintf(w, "| Duration | %.1f s |\n", stats.Duration.Seconds())
+	if config.RPS {
+		fmt.Fprintf(w, "| Requests | %d |\n", stats.Requests)
+		fmt.Fprintf(w, "| Requests per second | %.1f |\n", stats.RequestsPerSecond)
+		fmt.Fprintf(w, "| Request latency p50 | %.1f ms |\n", float64(stats.RequestP50.Microseconds())/1000)
+		fmt.Fprintf(w, "| Request latency p95 | %.1f ms |\n", float64(stats.RequestP95.Microseconds())/1000)
+	} else {
+		fmt.Fprintf(w, "| Average speed | %s |\n", speedText(stats.Speed, stats.Duration, config.Unit))
+	}
+	if config.Burst {
+		fmt.Fprintf(w, "| Peak burst speed (%v) | %s |\n", burstSampleInterval, formatSpeed(stats.PeakSpeed, config.Unit))
+	}
+	if len(stats.Protocols) > 0 {
+		fmt.Fprintf(w, "| Protocol | %s |\n", escapeMarkdown(strings.Join(stats.Protocols, ", ")))
+	}
+	if config.MaxBytes > 0 {
+		writeDataUsedMarkdown(w, stats.DataUsed, config.MaxBytes, stats.DataCapped)
+	}
+	if stats.Cancelled {
+		fmt.Fprintln(w, "| Interrupted | yes, results are partial |")
+	}
+	if stats.Error != nil {
+		fmt.Fprintf(w, "| Last error | %s |\n", escapeMarkdown(stats.Error.Error()))
+	}
+	return nil
+}
+
+// writeLatencyMarkdown adds the --bufferbloat rows to a transfer table
+func writeLatencyMarkdown(w io.Writer, latency *LoadedLatency) {
+	fmt.Fprintf(w, "| Idle latency | %.1f ms |\n", float64(latency.Idle.AvgRTT.Microseconds())/1000)
+	fmt.Fprintf(w, "| Loaded latency | %.1f ms |\n", float64(latency.Loaded.AvgRTT.Microseconds())/1000)
+	if grade := latency.Grade(); grade != "" {
+		fmt.Fprintf(w, "| Bufferbloat grade | %s |\n", grade)
+	}
+}
+
+// writeDataUsedMarkdown adds the --max-bytes row to a transfer table
+func writeDataUsedMarkdown(w io.Writer, used, limit int64, capped bool) {
+	note := ""
+	if capped {
+		note = ", reached"
+	}
+	fmt.Fprintf(w, "| Data used | %.2f MB of %.2f MB cap%s |\n",
+		float64(used)/(1024*1024), float64(limit)/(1024*1024), note)
+}
+
+// escapeMarkdown keeps cell contents from breaking the table layout
+func escapeMarkdown(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,73 @@
+// Package core fragment.go
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// probePacketSize returns the size of the IP packet carrying an echo probe
+// with size bytes of payload
+func probePacketSize(size int, ipv6 bool) int {
+	header := 20
+	if ipv6 {
+		header = 40
+	}
+	return header + 8 + size
+}
+
+// routeInterface returns the interface traffic to ip leaves through. A
+// UDP socket is connected to pick the route, which sends nothing. It is a
+// variable so tests can stand in a fixed interface.
+var routeInterface = func(ip net.IP) (*net.Interface, error) {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: 9})
+	if err != nil {
+		return nil, fmt.Errorf("finding route: %w", err)
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("listing interfaces: %w", err)
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(local) {
+				return &iface, nil
+			}
+		}
+	}
+	return nil, errors.New("no interface has the route's source address")
+}
+
+// checkProbeSize compares the packets of --size probes with the MTU of the
+// interface each target is reached through. Oversized probes are fragmented,
+// which only earns a warning on w, unless --df forbids it. Targets whose
+// route cannot be found are left for the ping to report.
+func checkProbeSize(w io.Writer, targets []string, size int, ipv6, dontFrag bool) error {
+	packet := probePacketSize(size, ipv6)
+	for _, target := range targets {
+		ipAddr, err := net.ResolveIPAddr(resolveNetwork(ipv6), target)
+		if err != nil {
+			continue
+		}
+		iface, err := routeInterface(ipAddr.IP)
+		if err != nil || iface.MTU <= 0 || packet <= iface.MTU {
+			continue
+		}
+		if dontFrag {
+			return fmt.Errorf("--size %d makes %d-byte packets, over the %d-byte MTU of %s toward %s, and --df forbids fragmenting them",
+				size, packet, iface.MTU, iface.Name, target)
+		}
+		fmt.Fprintf(w, "Warning: %s: %d-byte probes exceed the %d-byte MTU of %s and will be fragmented\n",
+			target, packet, iface.MTU, iface.Name)
+	}
+	return nil
+}
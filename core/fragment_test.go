@@ -0,0 +1,60 @@
+package core
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestCheckProbeSize(t *testing.T) {
+	defer func(saved func(net.IP) (*net.Interface, error)) { routeInterface = saved }(routeInterface)
+	routeInterface = func(net.IP) (*net.Interface, error) {
+		return &net.Interface{Name: "eth0", MTU: 1500}, nil
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		size        int
+		ipv6        bool
+		dontFrag    bool
+		wantWarning bool
+		wantErr     string
+	}{
+		{"default size", "127.0.0.1", defaultPayloadSize, false, false, false, ""},
+		{"fills the MTU", "127.0.0.1", 1472, false, true, false, ""},
+		{"one byte over", "127.0.0.1", 1473, false, false, true, ""},
+		{"one byte over with --df", "127.0.0.1", 1473, false, true, false, "--df forbids fragmenting"},
+		{"fills the MTU over IPv6", "::1", 1452, true, true, false, ""},
+		{"over the MTU over IPv6", "::1", 1472, true, false, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w bytes.Buffer
+			err := checkProbeSize(&w, []string{tt.target}, tt.size, tt.ipv6, tt.dontFrag)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkProbeSize: %v", err)
+			}
+			if warned := strings.Contains(w.String(), "will be fragmented"); warned != tt.wantWarning {
+				t.Errorf("warning %q, want one: %v", w.String(), tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestRouteInterfaceLoopback(t *testing.T) {
+	iface, err := routeInterface(net.IPv4(127, 0, 0, 1))
+	if err != nil {
+		t.Skipf("no loopback route: %v", err)
+	}
+	if iface.MTU <= 0 {
+		t.Errorf("%s has MTU %d, want a positive one", iface.Name, iface.MTU)
+	}
+}
@@ -0,0 +1,277 @@
+// Package core history.go
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"speedgo/commands"
+	"speedgo/core/stats"
+	"strings"
+	"time"
+)
+
+// HistoryRecord is one line of a --log file. Ping records carry the
+// latency fields, download and upload records the transfer fields.
+type HistoryRecord struct {
+	Time            time.Time `json:"time"`
+	Type            string    `json:"type"` // ping, download or upload
+	Target          string    `json:"target,omitempty"`
+	SpeedMbps       *float64  `json:"speed_mbps,omitempty"`
+	Bytes           int64     `json:"bytes,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"`
+	LatencyMs       *float64  `json:"latency_ms,omitempty"` // Left out without replies
+	JitterMs        *float64  `json:"jitter_ms,omitempty"`
+	LossPercent     *float64  `json:"loss_percent,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	Cancelled       bool      `json:"cancelled,omitempty"`
+}
+
+// AppendResult appends records to the JSON Lines file at path, creating it
+// if needed. All records go out in a single append-only write, so runs
+// logging to the same file at once do not interleave their lines.
+func AppendResult(path string, records ...HistoryRecord) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("encoding history record: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening history log: %w", err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("writing history log: %w", err)
+	}
+	return file.Close()
+}
+
+// ReadHistory reads every record of a --log file. Lines that are not a
+// valid record, such as the last line of a run killed while writing, are
+// skipped and counted instead of making the whole log unreadable.
+func ReadHistory(path string) (records []HistoryRecord, skipped int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening history log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			skipped++
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("reading history log: %w", err)
+	}
+	return records, skipped, nil
+}
+
+func pingRecords(now time.Time, results []PingResult) []HistoryRecord {
+	records := make([]HistoryRecord, len(results))
+	for i, result := range results {
+		loss := result.lossPercent()
+		record := HistoryRecord{
+			Time:        now,
+			Type:        "ping",
+			Target:      result.label(),
+			LossPercent: &loss,
+			Cancelled:   result.Cancelled,
+		}
+		if result.Received > 0 {
+			record.LatencyMs = optionalMilliseconds(result.AvgRTT)
+			record.JitterMs = optionalMilliseconds(result.Jitter)
+		}
+		records[i] = record
+	}
+	return records
+}
+
+func downloadRecord(now time.Time, stats DownloadStats) HistoryRecord {
+	speed := stats.Speed
+	return HistoryRecord{
+		Time:            now,
+		Type:            "download",
+		SpeedMbps:       &speed,
+		Bytes:           stats.BytesReceived,
+		DurationSeconds: stats.Duration.Seconds(),
+		Error:           transferErrorString(stats.Error),
+		Cancelled:       stats.Cancelled,
+	}
+}
+
+func uploadRecord(now time.Time, stats UploadStats) HistoryRecord {
+	speed := stats.Speed
+	return HistoryRecord{
+		Time:            now,
+		Type:            "upload",
+		SpeedMbps:       &speed,
+		Bytes:           stats.BytesSent,
+		DurationSeconds: stats.Duration.Seconds(),
+		Error:           transferErrorString(stats.Error),
+		Cancelled:       stats.Cancelled,
+	}
+}
+
+// transferErrorString leaves out requests cut off by the end of the test,
+// which are not worth keeping in the history
+func transferErrorString(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ""
+	}
+	return errorString(err)
+}
+
+// logResults appends records to path when --log is set
+func logResults(path string, records ...HistoryRecord) error {
+	if path == "" {
+		return nil
+	}
+	return AppendResult(path, records...)
+}
+
+// HistoryConfig holds the options of the history command
+type HistoryConfig struct {
+	Log string // JSON Lines file written by --log
+}
+
+func parseHistoryConfig(args []string) (*HistoryConfig, error) {
+	cmd := commands.HistoryCmd
+	if err := cmd.Parse(args); err != nil {
+		return nil, fmt.Errorf("parsing history arguments: %w", err)
+	}
+	if err := applyFlagSources(cmd); err != nil {
+		return nil, err
+	}
+
+	config := &HistoryConfig{Log: cmd.Lookup("log").Value.String()}
+	if config.Log == "" {
+		config.Log = cmd.Arg(0)
+	}
+	if config.Log == "" {
+		return nil, errors.New("no log file given (use --log or pass it as an argument)")
+	}
+	return config, nil
+}
+
+// RunHistory summarizes the results logged with --log
+func RunHistory(ctx context.Context, args []string) error {
+	config, err := parseHistoryConfig(args)
+	if err != nil {
+		return err
+	}
+
+	records, skipped, err := ReadHistory(config.Log)
+	if err != nil {
+		return err
+	}
+	printHistory(os.Stdout, config.Log, records, skipped)
+	return nil
+}
+
+func printHistory(w io.Writer, path string, records []HistoryRecord, skipped int) {
+	fmt.Fprintf(w, "HISTORY SUMMARY (%s)\n", path)
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+	if skipped > 0 {
+		lines := "lines"
+		if skipped == 1 {
+			lines = "line"
+		}
+		fmt.Fprintf(w, "Skipped %d malformed %s\n", skipped, lines)
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(w, "No results logged yet")
+		fmt.Fprintln(w, strings.Repeat("=", 50))
+		return
+	}
+
+	first, last := records[0].Time, records[0].Time
+	for _, record := range records {
+		first = minTime(first, record.Time)
+		last = maxTime(last, record.Time)
+	}
+	fmt.Fprintf(w, "Records: %d from %s to %s\n", len(records),
+		first.Local().Format("2006-01-02 15:04"), last.Local().Format("2006-01-02 15:04"))
+
+	for _, kind := range []string{"download", "upload"} {
+		var speeds []float64
+		for _, record := range records {
+			if record.Type == kind && record.SpeedMbps != nil {
+				speeds = append(speeds, *record.SpeedMbps)
+			}
+		}
+		if len(speeds) > 0 {
+			fmt.Fprintf(w, "%s: %s, average %.2f Mbps (min %.2f, max %.2f)\n",
+				strings.ToUpper(kind[:1])+kind[1:], runsText(len(speeds)),
+				stats.Mean(speeds), stats.Min(speeds), stats.Max(speeds))
+		}
+	}
+
+	// Ping results are summarized per target, in the order first logged
+	var targets []string
+	pings := make(map[string][]HistoryRecord)
+	for _, record := range records {
+		if record.Type != "ping" {
+			continue
+		}
+		if _, seen := pings[record.Target]; !seen {
+			targets = append(targets, record.Target)
+		}
+		pings[record.Target] = append(pings[record.Target], record)
+	}
+	for _, target := range targets {
+		var latencies, losses []float64
+		for _, record := range pings[target] {
+			if record.LatencyMs != nil {
+				latencies = append(latencies, *record.LatencyMs)
+			}
+			if record.LossPercent != nil {
+				losses = append(losses, *record.LossPercent)
+			}
+		}
+		latency := "no replies"
+		if len(latencies) > 0 {
+			latency = fmt.Sprintf("average %.1fms (min %.1f, max %.1f)",
+				stats.Mean(latencies), stats.Min(latencies), stats.Max(latencies))
+		}
+		fmt.Fprintf(w, "Ping %s: %s, %s, loss %.1f%%\n", target, runsText(len(pings[target])), latency, stats.Mean(losses))
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+}
+
+func runsText(n int) string {
+	if n == 1 {
+		return "1 run"
+	}
+	return fmt.Sprintf("%d runs", n)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
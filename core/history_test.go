@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"speedgo/commands"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHistoryRoundTrip logs results the way --log does and summarizes them
+// with the history command
+func TestHistoryRoundTrip(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ping := PingResult{Target: "1.1.1.1", Mode: pingModeICMP, RTTs: ms(10, 20, 30), Sent: 4, Received: 3, Lost: 1}
+	ping.calculateStats()
+
+	tests := []struct {
+		name        string
+		tail        string // Written after the logged records
+		wantRecords int
+		wantSkipped int
+		want        []string
+	}{
+		{
+			name:        "clean log",
+			wantRecords: 4,
+			want: []string{
+				"Records: 4",
+				"Download: 2 runs, average 150.00 Mbps (min 100.00, max 200.00)",
+				"Upload: 1 run, average 20.00 Mbps",
+				"Ping 1.1.1.1: 1 run, average 20.0ms (min 20.0, max 20.0), loss 25.0%",
+			},
+		},
+		{
+			name:        "truncated last line",
+			tail:        `{"time":"2024-03-01T12:05:00Z","type":"download","speed_mb`,
+			wantRecords: 4,
+			wantSkipped: 1,
+			want:        []string{"Skipped 1 malformed line", "Download: 2 runs"},
+		},
+		{
+			name:        "garbage and blank lines",
+			tail:        "not json\n\n{}\n[1,2]\n",
+			wantRecords: 5, // {} is a valid, if empty, record
+			wantSkipped: 2,
+			want:        []string{"Skipped 2 malformed lines", "Records: 5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history.jsonl")
+			records := []HistoryRecord{
+				downloadRecord(now, DownloadStats{BytesReceived: 125_000_000, Duration: 10 * time.Second, Speed: 100}),
+				downloadRecord(now.Add(time.Hour), DownloadStats{Speed: 200, Error: context.DeadlineExceeded}),
+				uploadRecord(now, UploadStats{Speed: 20, Error: errors.New("connection reset")}),
+			}
+			records = append(records, pingRecords(now, []PingResult{ping})...)
+			for _, record := range records {
+				if err := logResults(path, record); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.tail != "" {
+				file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				file.WriteString(tt.tail)
+				file.Close()
+			}
+
+			got, skipped, err := ReadHistory(path)
+			if err != nil {
+				t.Fatalf("ReadHistory: %v", err)
+			}
+			if len(got) != tt.wantRecords || skipped != tt.wantSkipped {
+				t.Errorf("read %d records and skipped %d, want %d and %d", len(got), skipped, tt.wantRecords, tt.wantSkipped)
+			}
+			if got[0].Bytes != 125_000_000 || got[1].Error != "" || got[2].Error != "connection reset" {
+				t.Errorf("records did not round-trip: %+v", got[:3])
+			}
+
+			resetFlags(t, commands.HistoryCmd)
+			var runErr error
+			out := captureStdout(t, func() { runErr = RunHistory(context.Background(), []string{path}) })
+			if runErr != nil {
+				t.Fatalf("RunHistory: %v", runErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("summary does not contain %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
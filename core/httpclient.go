@@ -0,0 +1,130 @@
+// Package core httpclient.go
+package core
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Idle pool limits of the transfer client. Every worker talks to the same
+// host, so the per-host limit is raised from the default of 2 to keep the
+// connections of finished requests for reuse instead of closing them.
+const (
+	maxIdleTransferConns = 100
+	idleConnTimeout      = 90 * time.Second
+)
+
+// speedClientConfig holds the settings of the HTTP client shared by the
+// workers of a transfer test
+type speedClientConfig struct {
+	RequireTLS13   bool
+	TLSCiphers     []uint16
+	HTTPVersion    string        // Forced HTTP version, or "" to negotiate
+	Insecure       bool          // Skip TLS certificate verification
+	Proxy          *url.URL      // Proxy for all requests; nil falls back to the environment
+	RequestTimeout time.Duration // Limit for each request, or 0 for none
+}
+
+// newSpeedClient returns the client all workers of one test send their
+// requests through, so they share a single connection pool
+func newSpeedClient(cfg speedClientConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = newTLSConfig(cfg.RequireTLS13, cfg.TLSCiphers)
+	transport.TLSClientConfig.InsecureSkipVerify = cfg.Insecure
+	transport.MaxIdleConns = maxIdleTransferConns
+	transport.MaxIdleConnsPerHost = maxIdleTransferConns
+	transport.IdleConnTimeout = idleConnTimeout
+	if cfg.Proxy != nil {
+		transport.Proxy = http.ProxyURL(cfg.Proxy)
+	}
+	// Measure the bytes on the wire, not a transparently decompressed body
+	transport.DisableCompression = true
+
+	// HTTP/2 multiplexes every worker over one connection, so forcing
+	// HTTP/1.1 gives each worker its own TCP stream
+	switch cfg.HTTPVersion {
+	case httpVersion11:
+		// A non-nil, empty TLSNextProto map disables HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case httpVersion2:
+		transport.ForceAttemptHTTP2 = true
+	}
+
+	var rt http.RoundTripper = transport
+	if cfg.HTTPVersion == httpVersion2 {
+		rt = requireHTTP2{next: transport}
+	}
+	return &http.Client{
+		Timeout:   cfg.RequestTimeout,
+		Transport: rt,
+	}
+}
+
+// ErrHTTP2Unavailable is returned with --http=2 for a response that came
+// over an older protocol, as it does from servers without HTTP/2 and for
+// plain http:// URLs
+var ErrHTTP2Unavailable = errors.New("server did not negotiate HTTP/2")
+
+// requireHTTP2 fails every response not made over HTTP/2, since the
+// transport only offers HTTP/2 and falls back to HTTP/1.1 without a word
+type requireHTTP2 struct {
+	next http.RoundTripper
+}
+
+func (t requireHTTP2) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ProtoMajor != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w (got %s)", ErrHTTP2Unavailable, resp.Proto)
+	}
+	return resp, nil
+}
+
+// warnInsecure flags --insecure on stderr, so that a run which accepted any
+// certificate is visible in logs even when results go to a file
+func warnInsecure() {
+	fmt.Fprintln(os.Stderr, "Warning: --insecure is set, TLS certificates will not be verified")
+}
+
+// parseProxyURL validates a --proxy value. net/http dials http, https and
+// socks5 proxies itself, so no other scheme is accepted.
+func parseProxyURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid --proxy %q (expected a URL like socks5://host:port)", raw)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return u, nil
+	default:
+		return nil, fmt.Errorf("unsupported --proxy scheme %q (expected http, https or socks5)", u.Scheme)
+	}
+}
+
+// wrapProxyError names the proxy in errors from connecting through it,
+// which net/http otherwise reports with the target URL only
+func wrapProxyError(err error, proxy *url.URL) error {
+	if proxy == nil {
+		return err
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && (opErr.Op == "proxyconnect" || strings.HasPrefix(opErr.Op, "socks")) {
+		return fmt.Errorf("connecting through proxy %s: %w", proxy.Redacted(), err)
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+// Package core inflight.go
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"speedgo/core/stats"
+)
+
+// pingPipelined probes one ICMP target without waiting for each reply before
+// sending the next request: requests go out every config.Interval while
+// fewer than config.InFlight are outstanding, and replies are matched to
+// their request by sequence number, in whatever order they arrive.
+func pingPipelined(ctx context.Context, session *pingSession, config *PingConfig, result *PingResult) {
+	var rto *stats.RTOEstimator
+	if config.AdaptiveTimeout {
+		rto = stats.NewRTOEstimator(config.Timeout, minAdaptiveTimeout,
+			maxAdaptiveTimeoutFactor*config.Timeout)
+	}
+
+	deadlines := make(map[int]time.Time) // Outstanding sequence numbers
+	nextSend := time.Now()
+	remaining := config.Count
+	goodStreak := 0
+
+	for remaining > 0 || len(deadlines) > 0 {
+		if ctx.Err() != nil {
+			result.Cancelled = true
+			return
+		}
+
+		now := time.Now()
+		for seq, deadline := range deadlines {
+			if now.Before(deadline) {
+				continue
+			}
+			delete(deadlines, seq)
+			delete(session.sent, seq)
+			if rto != nil {
+				rto.Backoff()
+			}
+			err := fmt.Errorf("probe %d: %w", seq, os.ErrDeadlineExceeded)
+			if config.Verbose {
+				fmt.Printf("Ping %s failed: %v\n", result.label(), err)
+			}
+			result.Lost++
+			result.Errors = append(result.Errors, err)
+			goodStreak = 0
+		}
+
+		canSend := remaining > 0 && len(deadlines) < config.InFlight
+		if canSend && !now.Before(nextSend) {
+			timeout := config.Timeout
+			if rto != nil {
+				timeout = rto.Timeout()
+			}
+			seq, err := session.send()
+			result.Sent++
+			remaining--
+			if err != nil {
+				result.Lost++
+				result.Errors = append(result.Errors, err)
+			} else {
+				deadlines[seq] = now.Add(timeout)
+			}
+			nextSend = now.Add(config.Interval)
+			continue
+		}
+
+		if len(deadlines) == 0 {
+			_ = sleepCtx(ctx, nextSend.Sub(now)) // Cancellation is handled at the top of the loop
+			continue
+		}
+
+		// Wait for a reply until the next probe is due or one expires
+		wait := nextSend
+		if !canSend {
+			wait = time.Time{}
+		}
+		for _, deadline := range deadlines {
+			if wait.IsZero() || deadline.Before(wait) {
+				wait = deadline
+			}
+		}
+
+		seq, rtt, err := session.receive(wait)
+		var exceeded *timeExceededError
+		if errors.As(err, &exceeded) {
+			delete(deadlines, seq)
+			if config.Verbose {
+				fmt.Printf("Ping %s failed: probe %d: %v\n", result.label(), seq, err)
+			}
+			result.Lost++
+			result.Errors = append(result.Errors, fmt.Errorf("probe %d: %w", seq, err))
+			goodStreak = 0
+			continue
+		}
+		if err != nil {
+			// Expiry is handled above. Anything else read here is a late
+			// reply or another packet on the socket, not a failed probe.
+			if config.Verbose && !isTimeout(err) && !errors.Is(err, errWrongReply) {
+				fmt.Printf("Ping %s: ignoring packet: %v\n", result.label(), err)
+			}
+			continue
+		}
+
+		delete(deadlines, seq)
+		if rto != nil {
+			rto.Update(rtt)
+		}
+		result.record(rtt)
+		ttl := ""
+		if session.lastTTL > 0 {
+			result.ReplyTTL = session.lastTTL
+			ttl = fmt.Sprintf(" TTL = %d", session.lastTTL)
+		}
+		if config.Verbose {
+			fmt.Printf("Ping %s: seq=%d RTT = %v%s\n", result.label(), seq, rtt, ttl)
+		}
+
+		if rtt < config.GoodRTT {
+			goodStreak++
+		} else {
+			goodStreak = 0
+		}
+		if config.StopOnGood && remaining > 0 && result.Lost == 0 && goodStreak >= config.GoodReplies {
+			// Let the outstanding probes finish so they are not counted as lost
+			result.StoppedEarly = true
+			remaining = 0
+		}
+	}
+}
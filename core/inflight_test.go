@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// requireRawICMP skips tests that need a raw ICMP socket when the process
+// may not open one
+func requireRawICMP(t *testing.T) {
+	t.Helper()
+	conn, err := net.ListenIP("ip4:icmp", &net.IPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Skipf("raw ICMP sockets unavailable: %v", err)
+	}
+	conn.Close()
+}
+
+func TestPingPipelinedLoopback(t *testing.T) {
+	requireRawICMP(t)
+
+	tests := []struct {
+		name         string
+		count        int
+		inFlight     int
+		stopOnGood   bool
+		wantReceived int
+	}{
+		{"one at a time uses the sequential path", 5, 1, false, 5},
+		{"several outstanding", 10, 4, false, 10},
+		{"more in flight than probes", 3, 8, false, 3},
+		{"stops on good replies", 20, 2, true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := pingTarget(context.Background(), pingProbe{target: "127.0.0.1", mode: pingModeICMP}, &PingConfig{
+				Count:       tt.count,
+				Timeout:     time.Second,
+				Interval:    5 * time.Millisecond,
+				InFlight:    tt.inFlight,
+				Size:        56,
+				StopOnGood:  tt.stopOnGood,
+				GoodReplies: 3,
+				GoodRTT:     time.Second,
+			})
+
+			if result.Lost != 0 || len(result.Errors) != 0 {
+				t.Fatalf("lost %d probes: %v", result.Lost, result.Errors)
+			}
+			if tt.stopOnGood {
+				// Probes already in flight when the streak completes still
+				// get their replies
+				if !result.StoppedEarly || result.Received < tt.wantReceived || result.Received > tt.wantReceived+tt.inFlight {
+					t.Errorf("StoppedEarly = %v after %d replies, want true after %d to %d",
+						result.StoppedEarly, result.Received, tt.wantReceived, tt.wantReceived+tt.inFlight)
+				}
+			} else if result.Received != tt.wantReceived {
+				t.Errorf("Received = %d, want %d", result.Received, tt.wantReceived)
+			}
+			if result.Sent != result.Received {
+				t.Errorf("Sent = %d, want one probe per reply (%d)", result.Sent, result.Received)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Package core interactive.go
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runInteractivePing pings all targets in rounds until the user presses 'q'
+// or interrupts, redrawing the statistics table after every round. When
+// stdin/stdout are not a terminal it degrades to a plain continuous mode that
+// logs each reply and stops on interrupt.
+func runInteractivePing(ctx context.Context, config *PingConfig) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	redraw := false
+	var out io.Writer = os.Stdout
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		restore, err := makeRaw(os.Stdin)
+		if err == nil {
+			defer restore()
+			redraw = true
+			out = rawWriter(os.Stdout)
+			go watchQuitKey(cancel)
+		}
+	}
+	if !redraw {
+		fmt.Println("Pinging continuously, press Ctrl-C to stop")
+	}
+
+	probes := config.probes()
+	results := make([]PingResult, len(probes))
+	for i, probe := range probes {
+		results[i] = newPingResult(probe, config)
+	}
+
+	roundConfig := *config
+	roundConfig.Count = 1
+	roundConfig.Verbose = false
+	roundConfig.StreamingStats = false
+
+	start := time.Now()
+	for round := 1; ctx.Err() == nil; round++ {
+		if round > 1 {
+			_ = sleepCtx(ctx, config.Interval) // Cancellation ends the loop below
+		}
+		roundResults := pingTargets(ctx, &roundConfig)
+		if ctx.Err() != nil {
+			break
+		}
+
+		for i, r := range roundResults {
+			for _, rtt := range r.RTTs {
+				results[i].record(rtt)
+			}
+			results[i].Sent += r.Sent
+			results[i].Lost += r.Lost
+			results[i].Errors = r.Errors
+			results[i].calculateStats()
+		}
+
+		if redraw {
+			fmt.Fprint(out, "\033[H\033[2J")
+			fmt.Fprintf(out, "Interactive ping: round %d, running for %v (press q to quit)\n",
+				round, time.Since(start).Round(time.Second))
+			printResults(out, results, config.Color)
+		} else {
+			for _, r := range roundResults {
+				if len(r.RTTs) > 0 {
+					fmt.Printf("%s: RTT = %v\n", r.label(), r.RTTs[0])
+				} else {
+					fmt.Printf("%s: no reply\n", r.label())
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "\nStopped after %v\n", time.Since(start).Round(time.Second))
+	printResults(out, results, config.Color)
+	return nil
+}
+
+// watchQuitKey cancels the run when 'q' or Ctrl-C is pressed on stdin
+func watchQuitKey(cancel context.CancelFunc) {
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		if n == 1 && (buf[0] == 'q' || buf[0] == 'Q' || buf[0] == keyCtrlC) {
+			cancel()
+			return
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package core json.go
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonEncoder writes machine-readable results. Durations are converted to
+// milliseconds (or seconds for test lengths) and errors to strings, since
+// neither marshals usefully on its own.
+type jsonEncoder struct {
+	lines bool // One compact document per line (JSON Lines) instead of indented JSON
+}
+
+type pingJSON struct {
+	Target         string    `json:"target"`
+	Mode           string    `json:"mode"`
+	Address        string    `json:"address,omitempty"`
+	Status         string    `json:"status"`
+	Sent           int       `json:"sent"`
+	Received       int       `json:"received"`
+	Lost           int       `json:"lost"`
+	LossPercent    float64   `json:"loss_percent"`
+	MinMs          *float64  `json:"min_ms"` // null without replies
+	AvgMs          *float64  `json:"avg_ms"`
+	MaxMs          *float64  `json:"max_ms"`
+	JitterMs       *float64  `json:"jitter_ms"`
+	P50Ms          *float64  `json:"p50_ms"`
+	P95Ms          *float64  `json:"p95_ms"`
+	P99Ms          *float64  `json:"p99_ms"`
+	RTTsMs         []float64 `json:"rtts_ms"`
+	ReplyTTL       int       `json:"reply_ttl,omitempty"`
+	Errors         []string  `json:"errors,omitempty"`
+	Cancelled      bool      `json:"cancelled,omitempty"`
+	StoppedEarly   bool      `json:"stopped_early,omitempty"`
+	PrivateAddress bool      `json:"private_address,omitempty"`
+}
+
+type transferJSON struct {
+	Bytes           int64    `json:"bytes"`
+	DurationSeconds float64  `json:"duration_seconds"`
+	SpeedMbps       float64  `json:"speed_mbps"`
+	Protocols       []string `json:"protocols,omitempty"`
+	RateLimited     int64    `json:"rate_limited,omitempty"`
+	Error           string   `json:"error,omitempty"`
+	Cancelled       bool     `json:"cancelled,omitempty"`
+
+	IdleLatencyMs    *float64 `json:"idle_latency_ms,omitempty"`
+	LoadedLatencyMs  *float64 `json:"loaded_latency_ms,omitempty"`
+	BufferbloatGrade string   `json:"bufferbloat_grade,omitempty"`
+
+	DataUsed   int64 `json:"data_used_bytes,omitempty"` // Set with --max-bytes
+	DataCapped bool  `json:"data_capped,omitempty"`
+}
+
+// setLatency fills in the --bufferbloat measurements, if any
+func (t *transferJSON) setLatency(latency *LoadedLatency) {
+	if latency == nil {
+		return
+	}
+	t.IdleLatencyMs = optionalMilliseconds(latency.Idle.AvgRTT)
+	t.LoadedLatencyMs = optionalMilliseconds(latency.Loaded.AvgRTT)
+	t.BufferbloatGrade = latency.Grade()
+}
+
+type downloadJSON struct {
+	transferJSON
+	SingleStreamMbps float64 `json:"single_stream_mbps,omitempty"`
+	CacheHits        int64   `json:"cache_hits,omitempty"`
+	CacheReported    int64   `json:"cache_reported,omitempty"`
+}
+
+type uploadJSON struct {
+	transferJSON
+	LogicalBytes      int64   `json:"logical_bytes,omitempty"`
+	PeakMbps          float64 `json:"peak_mbps,omitempty"`
+	Requests          int64   `json:"requests,omitempty"`
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+
+	// Request latency percentiles, set with --rps
+	RequestP50Ms *float64 `json:"request_p50_ms,omitempty"`
+	RequestP95Ms *float64 `json:"request_p95_ms,omitempty"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func optionalMilliseconds(d time.Duration) *float64 {
+	ms := milliseconds(d)
+	return &ms
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func (e jsonEncoder) write(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	if !e.lines {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
+
+func (e jsonEncoder) EncodePing(w io.Writer, config *PingConfig, results []PingResult) error {
+	out := make([]pingJSON, len(results))
+	for i, result := range results {
+		entry := pingJSON{
+			Target:         result.Target,
+			Mode:           result.Mode,
+			Address:        result.Address,
+			Status:         classifyResult(result).String(),
+			Sent:           result.Sent,
+			Received:       result.Received,
+			Lost:           result.Lost,
+			LossPercent:    result.lossPercent(),
+			RTTsMs:         make([]float64, len(result.RTTs)),
+			ReplyTTL:       result.ReplyTTL,
+			Cancelled:      result.Cancelled,
+			StoppedEarly:   result.StoppedEarly,
+			PrivateAddress: result.PrivateAddress,
+		}
+		if result.Received > 0 {
+			entry.MinMs = optionalMilliseconds(result.MinRTT)
+			entry.AvgMs = optionalMilliseconds(result.AvgRTT)
+			entry.MaxMs = optionalMilliseconds(result.MaxRTT)
+			entry.JitterMs = optionalMilliseconds(result.Jitter)
+			entry.P50Ms = optionalMilliseconds(result.Percentile(50))
+			entry.P95Ms = optionalMilliseconds(result.Percentile(95))
+			entry.P99Ms = optionalMilliseconds(result.Percentile(99))
+		}
+		for j, rtt := range result.RTTs {
+			entry.RTTsMs[j] = milliseconds(rtt)
+		}
+		for _, err := range result.Errors {
+			entry.Errors = append(entry.Errors, err.Error())
+		}
+		out[i] = entry
+	}
+	return e.write(w, out)
+}
+
+func (e jsonEncoder) EncodeDownload(w io.Writer, config *DownloadConfig, stats DownloadStats) error {
+	out := downloadJSON{
+		transferJSON: transferJSON{
+			Bytes:           stats.BytesReceived,
+			DurationSeconds: stats.Duration.Seconds(),
+			SpeedMbps:       stats.Speed,
+			Protocols:       stats.Protocols,
+			RateLimited:     stats.RateLimited,
+			Error:           errorString(stats.Error),
+			Cancelled:       stats.Cancelled,
+		},
+		SingleStreamMbps: stats.SingleStreamSpeed,
+		CacheHits:        stats.CacheHits,
+		CacheReported:    stats.CacheReported,
+	}
+	out.setLatency(stats.Latency)
+	if config.MaxBytes > 0 {
+		out.DataUsed, out.DataCapped = stats.DataUsed, stats.DataCapped
+	}
+	return e.write(w, out)
+}
+
+func (e jsonEncoder) EncodeUpload(w io.Writer, config *UploadConfig, stats UploadStats) error {
+	out := uploadJSON{
+		transferJSON: transferJSON{
+			Bytes:           stats.BytesSent,
+			DurationSeconds: stats.Duration.Seconds(),
+			SpeedMbps:       stats.Speed,
+			Protocols:       stats.Protocols,
+			RateLimited:     stats.RateLimited,
+			Error:           errorString(stats.Error),
+			Cancelled:       stats.Cancelled,
+		},
+		PeakMbps:          stats.PeakSpeed,
+		Requests:          stats.Requests,
+		RequestsPerSecond: stats.RequestsPerSecond,
+	}
+	if config.Compress {
+		out.LogicalBytes = stats.LogicalBytes
+	}
+	if config.RPS {
+		out.RequestP50Ms = optionalMilliseconds(stats.RequestP50)
+		out.RequestP95Ms = optionalMilliseconds(stats.RequestP95)
+	}
+	if config.MaxBytes > 0 {
+		out.DataUsed, out.DataCapped = stats.DataUsed, stats.DataCapped
+	}
+	out.setLatency(stats.Latency)
+	return e.write(w, out)
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncodePingJSON(t *testing.T) {
+	replied := PingResult{Target: "1.1.1.1", Mode: pingModeICMP, RTTs: ms(10, 20, 30), Sent: 4, Received: 3, Lost: 1}
+	replied.calculateStats()
+	unreachable := PingResult{
+		Target: "192.0.2.1", Mode: pingModeICMP, Sent: 2, Lost: 2,
+		Errors: []error{errors.New("probe 1: timeout"), errors.New("probe 2: timeout")},
+	}
+
+	tests := []struct {
+		name       string
+		result     PingResult
+		wantAvg    *float64
+		wantLoss   float64
+		wantRTTs   int
+		wantErrors int
+	}{
+		{"replies", replied, ptr(20.0), 25, 3, 0},
+		{"no replies", unreachable, nil, 100, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (jsonEncoder{}).EncodePing(&buf, &PingConfig{}, []PingResult{tt.result}); err != nil {
+				t.Fatalf("EncodePing: %v", err)
+			}
+			var out []pingJSON
+			if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+				t.Fatalf("decoding %s: %v", buf.String(), err)
+			}
+			if len(out) != 1 {
+				t.Fatalf("got %d results, want 1", len(out))
+			}
+			got := out[0]
+			if got.Target != tt.result.Target || got.Mode != tt.result.Mode {
+				t.Errorf("target, mode = %q, %q, want %q, %q", got.Target, got.Mode, tt.result.Target, tt.result.Mode)
+			}
+			if (got.AvgMs == nil) != (tt.wantAvg == nil) || (got.AvgMs != nil && *got.AvgMs != *tt.wantAvg) {
+				t.Errorf("avg_ms = %v, want %v", deref(got.AvgMs), deref(tt.wantAvg))
+			}
+			if got.LossPercent != tt.wantLoss {
+				t.Errorf("loss_percent = %v, want %v", got.LossPercent, tt.wantLoss)
+			}
+			if len(got.RTTsMs) != tt.wantRTTs || len(got.Errors) != tt.wantErrors {
+				t.Errorf("%d RTTs and %d errors, want %d and %d", len(got.RTTsMs), len(got.Errors), tt.wantRTTs, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestEncodeDownloadJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		stats     DownloadStats
+		wantError string
+	}{
+		{"complete", DownloadStats{BytesReceived: 1_250_000, Duration: 2 * time.Second, Speed: 5}, ""},
+		{"failed", DownloadStats{Error: ErrNoServersReachable}, ErrNoServersReachable.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (jsonEncoder{}).EncodeDownload(&buf, &DownloadConfig{}, tt.stats); err != nil {
+				t.Fatalf("EncodeDownload: %v", err)
+			}
+			var got downloadJSON
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("decoding %s: %v", buf.String(), err)
+			}
+			if got.Bytes != tt.stats.BytesReceived || got.SpeedMbps != tt.stats.Speed ||
+				got.DurationSeconds != tt.stats.Duration.Seconds() {
+				t.Errorf("got %+v, want the fields of %+v", got.transferJSON, tt.stats)
+			}
+			if got.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", got.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestEncodeUploadJSONRequestLatency(t *testing.T) {
+	stats := UploadStats{
+		BytesSent:         4096,
+		Duration:          time.Second,
+		Requests:          64,
+		RequestsPerSecond: 64,
+		RequestP50:        12500 * time.Microsecond,
+		RequestP95:        40 * time.Millisecond,
+	}
+	tests := []struct {
+		name    string
+		rps     bool
+		wantP50 *float64
+		wantP95 *float64
+	}{
+		{"with --rps", true, ptr(12.5), ptr(40.0)},
+		{"without --rps", false, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (jsonEncoder{}).EncodeUpload(&buf, &UploadConfig{RPS: tt.rps}, stats); err != nil {
+				t.Fatalf("EncodeUpload: %v", err)
+			}
+			var got uploadJSON
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("decoding %s: %v", buf.String(), err)
+			}
+			if deref(got.RequestP50Ms) != deref(tt.wantP50) || deref(got.RequestP95Ms) != deref(tt.wantP95) {
+				t.Errorf("request_p50_ms = %v, request_p95_ms = %v, want %v and %v",
+					deref(got.RequestP50Ms), deref(got.RequestP95Ms), deref(tt.wantP50), deref(tt.wantP95))
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func deref(p *float64) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
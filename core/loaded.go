@@ -0,0 +1,108 @@
+// Package core loaded.go
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"time"
+)
+
+// idleLatencyProbes is the number of pings sent before a transfer starts
+const idleLatencyProbes = 5
+
+// LoadedLatency compares the RTT to a reference host before and during a
+// transfer. A large increase under load indicates bufferbloat.
+type LoadedLatency struct {
+	Target string
+	Idle   PingResult
+	Loaded PingResult
+}
+
+// Increase returns how much the average RTT grew under load
+func (l *LoadedLatency) Increase() time.Duration {
+	return l.Loaded.AvgRTT - l.Idle.AvgRTT
+}
+
+// bufferbloatGrades maps the latency increase under load to a letter grade;
+// larger increases get an F
+var bufferbloatGrades = []struct {
+	limit time.Duration
+	grade string
+}{
+	{30 * time.Millisecond, "A"},
+	{60 * time.Millisecond, "B"},
+	{200 * time.Millisecond, "C"},
+	{400 * time.Millisecond, "D"},
+}
+
+// Grade rates the latency increase from A (barely noticeable) to F, or
+// returns "" when either measurement got no replies
+func (l *LoadedLatency) Grade() string {
+	if l.Idle.Received == 0 || l.Loaded.Received == 0 {
+		return ""
+	}
+	increase := l.Increase()
+	for _, g := range bufferbloatGrades {
+		if increase < g.limit {
+			return g.grade
+		}
+	}
+	return "F"
+}
+
+func latencyProbeConfig(count int) *PingConfig {
+	return &PingConfig{
+		Count:          count,
+		Timeout:        time.Second,
+		Interval:       time.Second,
+		Size:           defaultPayloadSize,
+		StreamingStats: true,
+	}
+}
+
+// measureIdleLatency pings target a few times on an otherwise quiet link
+func measureIdleLatency(ctx context.Context, target string) PingResult {
+	return pingTarget(ctx, pingProbe{target: target, mode: pingModeICMP}, latencyProbeConfig(idleLatencyProbes))
+}
+
+// startLoadedLatency pings target until ctx is done and then delivers the
+// result, so it can run alongside the transfer workers
+func startLoadedLatency(ctx context.Context, target string) <-chan PingResult {
+	result := make(chan PingResult, 1)
+	go func() {
+		result <- pingTarget(ctx, pingProbe{target: target, mode: pingModeICMP}, latencyProbeConfig(math.MaxInt32))
+	}()
+	return result
+}
+
+// latencyTarget picks the host to probe: the explicit target if given,
+// otherwise the host of the test endpoint
+func latencyTarget(target, endpoint string) string {
+	if target != "" {
+		return target
+	}
+	if u, err := url.Parse(endpoint); err == nil {
+		return u.Hostname()
+	}
+	return endpoint
+}
+
+func printLoadedLatency(w io.Writer, latency *LoadedLatency) {
+	if latency.Idle.Received == 0 || latency.Loaded.Received == 0 {
+		fmt.Fprintf(w, "Latency under load: no replies from %s\n", latency.Target)
+		return
+	}
+	fmt.Fprintf(w, "Idle latency: %.1fms, loaded latency: %.1fms (%+.1fms, %s)\n",
+		float64(latency.Idle.AvgRTT.Microseconds())/1000,
+		float64(latency.Loaded.AvgRTT.Microseconds())/1000,
+		float64(latency.Increase().Microseconds())/1000,
+		latency.Target)
+	fmt.Fprintf(w, "Loaded latency min/avg/max: %.1f/%.1f/%.1fms\n",
+		float64(latency.Loaded.MinRTT.Microseconds())/1000,
+		float64(latency.Loaded.AvgRTT.Microseconds())/1000,
+		float64(latency.Loaded.MaxRTT.Microseconds())/1000)
+	fmt.Fprintf(w, "Bufferbloat grade: %s\n", latency.Grade())
+}
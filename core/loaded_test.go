@@ -0,0 +1,44 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBufferbloatGrade(t *testing.T) {
+	tests := []struct {
+		name           string
+		idle, loaded   time.Duration
+		noIdle, noLoad bool // Measurement without replies
+		want           string
+	}{
+		{"no increase", 20 * time.Millisecond, 20 * time.Millisecond, false, false, "A"},
+		{"faster under load", 20 * time.Millisecond, 15 * time.Millisecond, false, false, "A"},
+		{"just under 30ms", 20 * time.Millisecond, 49 * time.Millisecond, false, false, "A"},
+		{"30ms", 20 * time.Millisecond, 50 * time.Millisecond, false, false, "B"},
+		{"60ms", 20 * time.Millisecond, 80 * time.Millisecond, false, false, "C"},
+		{"199ms", 20 * time.Millisecond, 219 * time.Millisecond, false, false, "C"},
+		{"200ms", 20 * time.Millisecond, 220 * time.Millisecond, false, false, "D"},
+		{"400ms", 20 * time.Millisecond, 420 * time.Millisecond, false, false, "F"},
+		{"seconds", 20 * time.Millisecond, 3 * time.Second, false, false, "F"},
+		{"no idle replies", 0, 50 * time.Millisecond, true, false, ""},
+		{"no loaded replies", 20 * time.Millisecond, 0, false, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			latency := &LoadedLatency{
+				Idle:   PingResult{AvgRTT: tt.idle, Received: 5},
+				Loaded: PingResult{AvgRTT: tt.loaded, Received: 10},
+			}
+			if tt.noIdle {
+				latency.Idle.Received = 0
+			}
+			if tt.noLoad {
+				latency.Loaded.Received = 0
+			}
+			if got := latency.Grade(); got != tt.want {
+				t.Errorf("Grade() for %v -> %v = %q, want %q", tt.idle, tt.loaded, got, tt.want)
+			}
+		})
+	}
+}
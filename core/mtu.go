@@ -0,0 +1,187 @@
+// Package core mtu.go
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// Path MTU discovery searches between the minimum IPv4 MTU and a jumbo
+// frame size. Probe sizes are whole IP packets: 20 bytes of IPv4 header and
+// 8 of ICMP header plus the echo payload.
+const (
+	minProbeMTU    = 68
+	maxProbeMTU    = 9000
+	mtuProbeHeader = 20 + 8
+)
+
+// errTooBig is returned for probes that exceed the local or path MTU
+var errTooBig = errors.New("packet too big")
+
+// MTUResult is the outcome of path MTU discovery to one target
+type MTUResult struct {
+	Target string
+	MTU    int // Largest packet that reached the target unfragmented
+	Error  error
+}
+
+// runMTUDiscovery binary-searches the path MTU to every target with
+// don't-fragment echo probes and prints the results
+func runMTUDiscovery(ctx context.Context, config *PingConfig) error {
+	fmt.Printf("Discovering path MTU to %d targets...\n", len(config.Targets))
+	for _, target := range config.Targets {
+		result := discoverMTU(ctx, target, config.Timeout)
+		if result.Error != nil {
+			fmt.Printf("%s: %v\n", target, result.Error)
+			continue
+		}
+		fmt.Printf("%s: path MTU %d bytes\n", target, result.MTU)
+	}
+	return nil
+}
+
+func discoverMTU(ctx context.Context, target string, timeout time.Duration) MTUResult {
+	result := MTUResult{Target: target}
+
+	ipAddr, err := net.ResolveIPAddr("ip4", target)
+	if err != nil {
+		result.Error = fmt.Errorf("resolving address: %w", err)
+		return result
+	}
+
+	conn, err := net.ListenIP("ip4:icmp", &net.IPAddr{IP: net.IPv4zero})
+	if err != nil {
+		result.Error = fmt.Errorf("creating ICMP connection: %w", err)
+		return result
+	}
+	defer conn.Close()
+
+	if err := setDontFragment(conn); err != nil {
+		result.Error = err
+		return result
+	}
+
+	probe := &mtuProbe{conn: conn, addr: ipAddr, id: os.Getpid() & 0xffff}
+	if err := probe.send(minProbeMTU, timeout); err != nil {
+		result.Error = fmt.Errorf("target does not answer minimum-size probes: %w", err)
+		return result
+	}
+
+	low, high := minProbeMTU, maxProbeMTU
+	for low < high && ctx.Err() == nil {
+		size := (low + high + 1) / 2
+		err := probe.send(size, timeout)
+		var tooBig *fragNeededError
+		switch {
+		case err == nil:
+			low = size
+		case errors.As(err, &tooBig) && tooBig.mtu >= low && tooBig.mtu < size:
+			// Trust the MTU advertised by the router, but keep verifying it
+			high = tooBig.mtu
+		default:
+			// Too big locally, or silently dropped by the path
+			high = size - 1
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		result.Error = err
+		return result
+	}
+
+	result.MTU = low
+	return result
+}
+
+// mtuProbe sends don't-fragment echo requests of a chosen size
+type mtuProbe struct {
+	conn *net.IPConn
+	addr *net.IPAddr
+	id   int
+	seq  int
+}
+
+// fragNeededError reports an ICMP "fragmentation needed" reply and the
+// next-hop MTU it advertised (0 if the router did not set one)
+type fragNeededError struct {
+	mtu int
+}
+
+func (e *fragNeededError) Error() string {
+	return fmt.Sprintf("fragmentation needed (next-hop MTU %d)", e.mtu)
+}
+
+func (e *fragNeededError) Unwrap() error { return errTooBig }
+
+// send transmits one probe whose IP packet is size bytes long and waits for
+// the echo reply
+func (p *mtuProbe) send(size int, timeout time.Duration) error {
+	p.seq++
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{
+			ID:   p.id,
+			Seq:  p.seq,
+			Data: make([]byte, size-mtuProbeHeader),
+		},
+	}
+	msgBytes, err := msg.Marshal(nil)
+	if err != nil {
+		return fmt.Errorf("marshaling ICMP message: %w", err)
+	}
+
+	if _, err := p.conn.WriteTo(msgBytes, p.addr); err != nil {
+		if errors.Is(err, syscall.EMSGSIZE) {
+			return errTooBig
+		}
+		return fmt.Errorf("sending ICMP message: %w", err)
+	}
+
+	if err := p.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return fmt.Errorf("setting read deadline: %w", err)
+	}
+	reply := make([]byte, maxProbeMTU)
+	for {
+		n, _, err := p.conn.ReadFrom(reply)
+		if err != nil {
+			return fmt.Errorf("reading ICMP reply: %w", err)
+		}
+
+		if mtu, id, seq, ok := parseFragNeeded(reply[:n]); ok {
+			if id == p.id && seq == p.seq {
+				return &fragNeededError{mtu: mtu}
+			}
+			continue
+		}
+
+		rm, err := icmp.ParseMessage(protocolICMP, reply[:n])
+		if err != nil || rm.Type != ipv4.ICMPTypeEchoReply {
+			continue // Our own request on loopback, or unrelated traffic
+		}
+		if echo, ok := rm.Body.(*icmp.Echo); ok && echo.ID == p.id && echo.Seq == p.seq {
+			return nil
+		}
+	}
+}
+
+// parseFragNeeded recognizes an ICMPv4 "destination unreachable,
+// fragmentation needed" message (type 3, code 4) and returns the next-hop
+// MTU from bytes 6-7 of its header (RFC 1191), plus the echo ID and sequence
+// of the probe that triggered it, taken from the quoted original datagram.
+func parseFragNeeded(b []byte) (mtu, id, seq int, ok bool) {
+	const headerLen = 8
+	if len(b) < headerLen+20 || b[0] != 3 || b[1] != 4 {
+		return 0, 0, 0, false
+	}
+	mtu = int(binary.BigEndian.Uint16(b[6:8]))
+	id, seq, _ = quotedEcho(b[headerLen:], false)
+	return mtu, id, seq, true
+}
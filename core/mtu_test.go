@@ -0,0 +1,55 @@
+package core
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// quotedIPv4Probe returns the start of an echo request datagram as an ICMP
+// error quotes it: an IPv4 header of ihl 32-bit words, then the echo header
+func quotedIPv4Probe(ihl, id, seq int) []byte {
+	b := make([]byte, ihl*4+8)
+	b[0] = 0x40 | byte(ihl)
+	echo := b[ihl*4:]
+	echo[0] = 8 // Echo request
+	binary.BigEndian.PutUint16(echo[4:6], uint16(id))
+	binary.BigEndian.PutUint16(echo[6:8], uint16(seq))
+	return b
+}
+
+// icmpError returns an ICMPv4 error message of typ and code whose second
+// header word is rest, quoting quoted
+func icmpError(typ, code byte, rest uint32, quoted []byte) []byte {
+	b := make([]byte, 8, 8+len(quoted))
+	b[0], b[1] = typ, code
+	binary.BigEndian.PutUint32(b[4:8], rest)
+	return append(b, quoted...)
+}
+
+func TestParseFragNeeded(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     []byte
+		wantMTU int
+		wantID  int
+		wantSeq int
+		wantOK  bool
+	}{
+		{"fragmentation needed", icmpError(3, 4, 1400, quotedIPv4Probe(5, 0x1234, 7)), 1400, 0x1234, 7, true},
+		{"with IP options", icmpError(3, 4, 1280, quotedIPv4Probe(6, 42, 65535)), 1280, 42, 65535, true},
+		// Pre-RFC 1191 routers leave the MTU field zero
+		{"no MTU reported", icmpError(3, 4, 0, quotedIPv4Probe(5, 1, 2)), 0, 1, 2, true},
+		{"other unreachable code", icmpError(3, 1, 1400, quotedIPv4Probe(5, 1, 2)), 0, 0, 0, false},
+		{"time exceeded", icmpError(11, 0, 0, quotedIPv4Probe(5, 1, 2)), 0, 0, 0, false},
+		{"truncated", icmpError(3, 4, 1400, nil), 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mtu, id, seq, ok := parseFragNeeded(tt.msg)
+			if ok != tt.wantOK || mtu != tt.wantMTU || id != tt.wantID || seq != tt.wantSeq {
+				t.Errorf("parseFragNeeded = %d, %d, %d, %v, want %d, %d, %d, %v",
+					mtu, id, seq, ok, tt.wantMTU, tt.wantID, tt.wantSeq, tt.wantOK)
+			}
+		})
+	}
+}
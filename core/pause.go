@@ -0,0 +1,150 @@
+// Package core pause.go
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// pauseController lets the user pause and resume a transfer test with the
+// space bar. Workers stop issuing new requests while paused, open
+// connections are kept, and paused time is excluded from the speed. A nil
+// controller never pauses.
+type pauseController struct {
+	clock    Clock
+	out      io.Writer // Where the pause state is shown, away from the results
+	mu       sync.Mutex
+	paused   bool
+	pausedAt time.Time
+	total    time.Duration // Completed pauses
+	resumed  chan struct{} // Closed when the current pause ends
+}
+
+// startPauseControl puts the terminal in raw mode and watches for the space
+// bar, timing pauses with clock and reporting them on out. When stdin
+// or stdout is not a terminal it returns a nil controller. The returned
+// function restores the terminal.
+func startPauseControl(clock Clock, out io.Writer) (*pauseController, func()) {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return nil, func() {}
+	}
+	restore, err := makeRaw(os.Stdin)
+	if err != nil {
+		return nil, func() {}
+	}
+
+	p := &pauseController{clock: clock, out: rawWriter(out)}
+	go p.watchKeys()
+	fmt.Fprintln(out, "Press space to pause or resume")
+	return p, restore
+}
+
+func (p *pauseController) watchKeys() {
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		if n != 1 {
+			continue
+		}
+		switch buf[0] {
+		case ' ':
+			p.toggle()
+		case keyCtrlC:
+			// Raw mode swallows the signal; raise it so the test is
+			// interrupted as usual and the terminal restored
+			if self, err := os.FindProcess(os.Getpid()); err == nil {
+				_ = self.Signal(os.Interrupt)
+			}
+			return
+		}
+	}
+}
+
+// writer returns w for results written while the test is running, with
+// line ends fixed up for the raw terminal
+func (p *pauseController) writer(w io.Writer) io.Writer {
+	if p == nil {
+		return w
+	}
+	return rawWriter(w)
+}
+
+func (p *pauseController) toggle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		p.total += p.clock.Since(p.pausedAt)
+		p.paused = false
+		close(p.resumed)
+		fmt.Fprint(p.out, "\r[resumed]\n")
+		return
+	}
+	p.paused = true
+	p.pausedAt = p.clock.Now()
+	p.resumed = make(chan struct{})
+	fmt.Fprint(p.out, "\r[paused, press space to resume]\n")
+}
+
+// wait blocks while the test is paused, returning early if ctx is done
+func (p *pauseController) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	paused, resumed := p.paused, p.resumed
+	p.mu.Unlock()
+	if !paused {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pausedFor returns the total time spent paused, including a pause still in
+// progress
+func (p *pauseController) pausedFor() time.Duration {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	total := p.total
+	if p.paused {
+		total += p.clock.Since(p.pausedAt)
+	}
+	return total
+}
+
+// expire cancels the test once it has run unpaused for d, so pausing does
+// not eat into the measurement window
+func (p *pauseController) expire(ctx context.Context, d time.Duration, cancel context.CancelFunc) {
+	defer cancel()
+
+	start := p.clock.Now()
+	base := p.pausedFor()
+	for {
+		remaining := d - (p.clock.Since(start) - (p.pausedFor() - base))
+		if remaining <= 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.clock.After(remaining):
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPauseReportsOnOut(t *testing.T) {
+	var out bytes.Buffer
+	pause := &pauseController{clock: newFakeClock(time.Unix(0, 0)), out: &out}
+
+	stdout := captureStdout(t, func() {
+		pause.toggle()
+		pause.toggle()
+	})
+
+	if stdout != "" {
+		t.Errorf("printed %q to stdout, where it would corrupt json, csv or prometheus results", stdout)
+	}
+	want := "\r[paused, press space to resume]\n\r[resumed]\n"
+	if out.String() != want {
+		t.Errorf("wrote %q, want %q", out.String(), want)
+	}
+}
@@ -7,42 +7,246 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"speedgo/commands"
+	"speedgo/core/stats"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 type PingConfig struct {
 	Targets     []string
 	Count       int
 	Timeout     time.Duration
+	Interval    time.Duration // Pause between probes to the same target
 	Concurrency int
+	InFlight    int  // ICMP probes per target that may await a reply at once
+	DSCP        int  // Differentiated services code point marked on ICMP probes
+	Size        int  // ICMP echo payload bytes
+	DontFrag    bool // Set the IPv4 don't-fragment bit on ICMP probes
+	TTL         int  // Outgoing TTL (IPv6 hop limit) of ICMP probes; 0 keeps the system default
+
+	// AdaptiveTimeout starts each target at Timeout and then tracks the
+	// observed RTT (see stats.RTOEstimator), within adaptive timeout bounds
+	AdaptiveTimeout bool
+
 	Verbose     bool
+	Color       bool
+	Interactive bool
+	Format      string
+	Template    string // text/template file used instead of Format
+	Compact     bool
+	Out         string // Results destination: "-" (stdout), "stderr" or a file path
+	Log         string // JSON Lines file results are appended to, or ""
+	Mode        string // Probe protocol: icmp, tcp or both
+	Port        int    // Port connected to in tcp mode
+	IPv6        bool   // Resolve targets to IPv6 addresses only
+
+	// FlagPrivate warns when a hostname resolves to a private or bogon
+	// address; FailPrivate additionally makes the run fail
+	FlagPrivate bool
+	FailPrivate bool
+
+	// FailOn is the lowest status (see classifyResult) that makes the run
+	// fail, or failNever
+	FailOn PingStatus
+
+	Duplicates int // Targets dropped because they resolved to an earlier target
+
+	// StopOnGood ends a target's probes once GoodReplies consecutive replies
+	// were faster than GoodRTT with no loss so far
+	StopOnGood  bool
+	GoodReplies int
+	GoodRTT     time.Duration
+
+	DiscoverMTU bool // Search for the path MTU instead of measuring latency
+
+	// StreamingStats keeps constant-memory running statistics and a bounded
+	// sample of RTTs instead of every RTT, for very long runs
+	StreamingStats bool
 }
 
 type PingResult struct {
-	Target string
-	RTTs   []time.Duration
-	MinRTT time.Duration
-	MaxRTT time.Duration
-	AvgRTT time.Duration
-	Lost   int
-	Errors []error
+	Target   string
+	Mode     string          // Protocol this result was measured with: icmp or tcp
+	RTTs     []time.Duration // Every RTT, or a bounded sample with streaming stats
+	Sent     int             // Probes sent, or Count when the target could not be probed
+	Received int             // Number of replies
+	MinRTT   time.Duration
+	MaxRTT   time.Duration
+	AvgRTT   time.Duration
+	Jitter   time.Duration // Mean absolute difference between consecutive RTTs
+	Lost     int
+	Errors   []error
+	ReplyTTL int // TTL of the last ICMP reply, 0 if unknown
+
+	// Cancelled is set when the run was stopped before all probes were
+	// sent. Unsent probes are not counted as lost.
+	Cancelled bool
+
+	// StoppedEarly is set when --stop-on-good ended the probes because the
+	// target was already clearly healthy
+	StoppedEarly bool
+
+	// Address is the resolved IP. PrivateAddress is set with --flag-private
+	// when a hostname resolved to a private or bogon range.
+	Address        string
+	PrivateAddress bool
+
+	running   *stats.Running[time.Duration]
+	reservoir *stats.Reservoir[time.Duration]
+
+	// Jitter inputs with streaming stats, where RTTs is not in order
+	lastRTT     time.Duration
+	jitterTotal time.Duration
+}
+
+// ErrPrivateAddress is returned with --fail-private when any hostname
+// resolved to a private or bogon address
+var ErrPrivateAddress = errors.New("target resolved to a private or bogon address")
+
+// Bounds of the per-probe timeout with --probe-timeout-grows. The upper
+// bound is a multiple of --timeout so a slow link can still be measured.
+const (
+	minAdaptiveTimeout       = 20 * time.Millisecond
+	maxAdaptiveTimeoutFactor = 10
+)
+
+// minPingInterval keeps --interval from flooding targets
+const minPingInterval = 10 * time.Millisecond
+
+// maxDSCP is the largest 6-bit differentiated services code point
+const maxDSCP = 63
+
+// defaultPayloadSize matches the classic ping payload; maxPayloadSize is
+// the largest echo payload that fits in an IPv4 packet
+const (
+	defaultPayloadSize = 56
+	maxPayloadSize     = 65535 - mtuProbeHeader
+)
+
+// reservoirSize bounds the RTT sample kept per target with streaming stats
+const reservoirSize = 1024
+
+// Ping probe protocols selected with --mode
+const (
+	pingModeICMP = "icmp"
+	pingModeTCP  = "tcp"
+	pingModeBoth = "both"
+)
+
+// pingProbe is one target pinged over one protocol; --mode=both yields two
+// probes per target
+type pingProbe struct {
+	target string
+	mode   string
+}
+
+// probes expands the targets into the probes to run, keeping the rows for
+// one target next to each other
+func (c *PingConfig) probes() []pingProbe {
+	modes := []string{c.Mode}
+	switch c.Mode {
+	case "":
+		modes = []string{pingModeICMP}
+	case pingModeBoth:
+		modes = []string{pingModeICMP, pingModeTCP}
+	}
+
+	var probes []pingProbe
+	for _, target := range c.Targets {
+		for _, mode := range modes {
+			probes = append(probes, pingProbe{target: target, mode: mode})
+		}
+	}
+	return probes
+}
+
+func newPingResult(probe pingProbe, config *PingConfig) PingResult {
+	if config.StreamingStats {
+		return PingResult{
+			Target:    probe.target,
+			Mode:      probe.mode,
+			running:   &stats.Running[time.Duration]{},
+			reservoir: stats.NewReservoir[time.Duration](reservoirSize),
+		}
+	}
+	return PingResult{
+		Target: probe.target,
+		Mode:   probe.mode,
+		RTTs:   make([]time.Duration, 0, config.Count),
+	}
+}
+
+// label is the name a result is shown under; TCP rows are marked so they
+// can be told apart from the ICMP row of the same target
+func (r *PingResult) label() string {
+	if r.Mode == pingModeTCP {
+		return r.Target + " (tcp)"
+	}
+	return r.Target
+}
+
+// record adds one successful reply to the result
+func (r *PingResult) record(rtt time.Duration) {
+	r.Received++
+	if r.running != nil {
+		if r.Received > 1 {
+			r.jitterTotal += (rtt - r.lastRTT).Abs()
+		}
+		r.lastRTT = rtt
+		r.running.Add(rtt)
+		r.reservoir.Add(rtt)
+		return
+	}
+	r.RTTs = append(r.RTTs, rtt)
+}
+
+// Percentile returns the p-th percentile (0-100) RTT, interpolating between
+// replies, or zero without replies. RTTs keeps its original order. With
+// streaming stats it is computed over the retained sample.
+func (r *PingResult) Percentile(p float64) time.Duration {
+	return stats.Percentile(r.RTTs, p)
+}
+
+// lossPercent returns the share of sent probes that got no reply
+func (r *PingResult) lossPercent() float64 {
+	if r.Sent == 0 {
+		return 0
+	}
+	return float64(r.Lost) * 100 / float64(r.Sent)
 }
 
 type pingSession struct {
-	conn   *icmp.PacketConn
+	conn   *net.IPConn
 	id     int
 	seq    int
 	target string
+	v6     bool              // Target is an IPv6 address, probed with ICMPv6
+	size   int               // Echo payload bytes
+	sent   map[int]time.Time // Send time of each outstanding sequence number
+
+	// Readers that report the TTL of received packets, and the TTL of the
+	// last matching reply (0 when the platform does not report it)
+	p4      *ipv4.PacketConn
+	p6      *ipv6.PacketConn
+	lastTTL int
 }
 
+// errWrongReply is returned for an ICMP packet that does not answer an
+// outstanding probe of the session. A raw socket sees the ICMP traffic of
+// every process on the host, including other pings and, on loopback, the
+// session's own requests.
+var errWrongReply = errors.New("received wrong ICMP reply")
+
 // splitAndTrim 分割并清理字符串
 func splitAndTrim(input, sep string) []string {
 	parts := strings.Split(input, sep)
@@ -55,17 +259,57 @@ func splitAndTrim(input, sep string) []string {
 	return result
 }
 
-// splitTargets 分割并验证目标地址
-func splitTargets(targets string) []string {
+// splitTargets 分割并验证目标地址. Targets are separated by sep and by any
+// whitespace, so comma lists, space lists and pasted multi-line lists all
+// work; an empty sep splits on whitespace only.
+func splitTargets(targets, sep string) []string {
+	parts := []string{targets}
+	if sep != "" {
+		parts = splitAndTrim(targets, sep)
+	}
+
 	var result []string
-	for _, target := range splitAndTrim(targets, ",") {
-		if net.ParseIP(target) != nil || isValidHostname(target) {
-			result = append(result, target)
+	for _, part := range parts {
+		for _, target := range strings.Fields(part) {
+			if net.ParseIP(target) != nil || isValidHostname(target) {
+				result = append(result, target)
+			}
 		}
 	}
 	return result
 }
 
+// resolveNetwork returns the network targets are resolved on: IPv6 only
+// with --ipv6, otherwise either family, preferring IPv4 when a host has both
+func resolveNetwork(ipv6 bool) string {
+	if ipv6 {
+		return "ip6"
+	}
+	return "ip"
+}
+
+// dedupeTargets drops targets that repeat an earlier one, comparing by the
+// address they resolve to on network (see resolveNetwork) where possible, so
+// that a hostname and its IP collapse too. Unresolvable targets are compared
+// by name and kept for the ping to report. It returns the remaining targets
+// and how many were dropped.
+func dedupeTargets(targets []string, network string) ([]string, int) {
+	seen := make(map[string]bool, len(targets))
+	var result []string
+	for _, target := range targets {
+		key := strings.ToLower(target)
+		if ipAddr, err := net.ResolveIPAddr(network, target); err == nil {
+			key = ipAddr.String()
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, target)
+	}
+	return result, len(targets) - len(result)
+}
+
 // isValidHostname 验证主机名
 func isValidHostname(hostname string) bool {
 	if len(hostname) == 0 || len(hostname) > 255 {
@@ -79,24 +323,126 @@ func NewPingConfig(args []string) (*PingConfig, error) {
 	if err := cmd.Parse(args); err != nil {
 		return nil, fmt.Errorf("parsing ping arguments: %w", err)
 	}
+	if err := applyFlagSources(cmd); err != nil {
+		return nil, err
+	}
 
 	targetsStr := cmd.Lookup("targets").Value.String()
 	count := cmd.Lookup("count").Value.(flag.Getter).Get().(int)
+	if count < 1 {
+		return nil, fmt.Errorf("invalid --count %d (expected at least 1 probe)", count)
+	}
 	timeout := cmd.Lookup("timeout").Value.(flag.Getter).Get().(time.Duration)
+	interval := cmd.Lookup("interval").Value.(flag.Getter).Get().(time.Duration)
+	if interval < minPingInterval {
+		return nil, fmt.Errorf("--interval must be at least %v", minPingInterval)
+	}
 	concurrency := cmd.Lookup("concurrency").Value.(flag.Getter).Get().(int)
+	if concurrency < 1 {
+		return nil, fmt.Errorf("invalid --concurrency %d (expected at least 1 target at a time)", concurrency)
+	}
+	inFlight := cmd.Lookup("inflight").Value.(flag.Getter).Get().(int)
+	if inFlight < 1 {
+		return nil, errors.New("--inflight must be at least 1")
+	}
+	dscp := cmd.Lookup("dscp").Value.(flag.Getter).Get().(int)
+	if dscp < 0 || dscp > maxDSCP {
+		return nil, fmt.Errorf("invalid --dscp %d (expected 0-%d)", dscp, maxDSCP)
+	}
+	ttl := cmd.Lookup("ttl").Value.(flag.Getter).Get().(int)
+	if ttl < 0 || ttl > maxTTL {
+		return nil, fmt.Errorf("invalid --ttl %d (expected 1-%d)", ttl, maxTTL)
+	}
+	size := cmd.Lookup("size").Value.(flag.Getter).Get().(int)
+	if size < 0 || size > maxPayloadSize {
+		return nil, fmt.Errorf("invalid --size %d (expected 0-%d)", size, maxPayloadSize)
+	}
 	verbose := cmd.Lookup("verbose").Value.(flag.Getter).Get().(bool)
+	interactive := cmd.Lookup("interactive").Value.(flag.Getter).Get().(bool)
+	mode := cmd.Lookup("mode").Value.String()
+	port := cmd.Lookup("port").Value.(flag.Getter).Get().(int)
+	failPrivate := cmd.Lookup("fail-private").Value.(flag.Getter).Get().(bool)
+	flagPrivate := failPrivate || cmd.Lookup("flag-private").Value.(flag.Getter).Get().(bool)
+
+	switch mode {
+	case pingModeICMP, pingModeTCP, pingModeBoth:
+	default:
+		return nil, fmt.Errorf("invalid --mode %q (expected icmp, tcp or both)", mode)
+	}
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid --port %d", port)
+	}
 
-	targets := splitTargets(targetsStr)
+	color, err := parseColorMode(cmd.Lookup("color").Value.String())
+	if err != nil {
+		return nil, err
+	}
+	failOn, err := parseFailOn(cmd.Lookup("fail-on").Value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	targets := DefaultPingTargets
+	if targetsStr != "" {
+		targets = splitTargets(targetsStr, cmd.Lookup("target-sep").Value.String())
+	}
 	if len(targets) == 0 {
 		return nil, errors.New("no valid targets provided")
 	}
 
+	if cmd.Lookup("good-replies").Value.(flag.Getter).Get().(int) < 1 {
+		return nil, errors.New("--good-replies must be at least 1")
+	}
+
+	ipv6 := cmd.Lookup("ipv6").Value.(flag.Getter).Get().(bool) || cmd.Lookup("6").Value.(flag.Getter).Get().(bool)
+	var duplicates int
+	if !cmd.Lookup("allow-duplicates").Value.(flag.Getter).Get().(bool) {
+		targets, duplicates = dedupeTargets(targets, resolveNetwork(ipv6))
+	}
+
+	dontFrag := cmd.Lookup("df").Value.(flag.Getter).Get().(bool)
+	if mode != pingModeTCP {
+		if err := checkProbeSize(os.Stderr, targets, size, ipv6, dontFrag); err != nil {
+			return nil, err
+		}
+	}
+
 	return &PingConfig{
 		Targets:     targets,
 		Count:       count,
 		Timeout:     timeout,
+		Interval:    interval,
 		Concurrency: concurrency,
+		InFlight:    inFlight,
+		DSCP:        dscp,
+		Size:        size,
+		TTL:         ttl,
+		DontFrag:    dontFrag,
 		Verbose:     verbose,
+		Color:       color,
+		Interactive: interactive,
+		Format:      cmd.Lookup("format").Value.String(),
+		Template:    cmd.Lookup("template").Value.String(),
+		Compact:     cmd.Lookup("compact").Value.(flag.Getter).Get().(bool),
+		Out:         cmd.Lookup("out").Value.String(),
+		Log:         cmd.Lookup("log").Value.String(),
+		Mode:        mode,
+		Port:        port,
+		IPv6:        ipv6,
+		FlagPrivate: flagPrivate,
+		FailPrivate: failPrivate,
+		FailOn:      failOn,
+		Duplicates:  duplicates,
+
+		AdaptiveTimeout: cmd.Lookup("probe-timeout-grows").Value.(flag.Getter).Get().(bool),
+
+		StopOnGood:  cmd.Lookup("stop-on-good").Value.(flag.Getter).Get().(bool),
+		GoodReplies: cmd.Lookup("good-replies").Value.(flag.Getter).Get().(int),
+		GoodRTT:     cmd.Lookup("good-rtt").Value.(flag.Getter).Get().(time.Duration),
+
+		DiscoverMTU: cmd.Lookup("discover-mtu").Value.(flag.Getter).Get().(bool),
+
+		StreamingStats: cmd.Lookup("streaming-stats").Value.(flag.Getter).Get().(bool),
 	}, nil
 }
 
@@ -106,89 +452,215 @@ func RunPing(ctx context.Context, args []string) error {
 		return err
 	}
 
-	fmt.Printf("Starting ping test to %d targets...\n", len(config.Targets))
+	if config.Interactive {
+		return runInteractivePing(ctx, config)
+	}
+	if config.DiscoverMTU {
+		return runMTUDiscovery(ctx, config)
+	}
+
+	encoder, err := newEncoder(config.Format, config.Template)
+	if err != nil {
+		return err
+	}
+
+	banner := bannerWriter(config.Format)
+	fmt.Fprintf(banner, "Starting ping test to %d targets...\n", len(config.Targets))
+	if config.Duplicates > 0 {
+		fmt.Fprintf(banner, "Collapsed %d duplicate targets (use --allow-duplicates to keep them)\n", config.Duplicates)
+	}
 	results := pingTargets(ctx, config)
-	printResults(results)
-	return nil
+	err = writeResults(config.Out, func(w io.Writer) error {
+		return encoder.EncodePing(w, config, results)
+	})
+	if err != nil {
+		return err
+	}
+	if err := logResults(config.Log, pingRecords(time.Now(), results)...); err != nil {
+		return err
+	}
+
+	if config.FailPrivate {
+		for _, result := range results {
+			if result.PrivateAddress {
+				return fmt.Errorf("%s: %w", result.Target, ErrPrivateAddress)
+			}
+		}
+	}
+	return checkHealth(results, config.FailOn)
 }
 
 func pingTargets(ctx context.Context, config *PingConfig) []PingResult {
-	results := make([]PingResult, len(config.Targets))
+	probes := config.probes()
+	results := make([]PingResult, len(probes))
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, config.Concurrency)
 
-	for i, target := range config.Targets {
+	for i, probe := range probes {
 		wg.Add(1)
-		go func(idx int, target string) {
+		go func(idx int, probe pingProbe) {
 			defer wg.Done()
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			results[idx] = pingTarget(ctx, target, config)
+			results[idx] = pingTarget(ctx, probe, config)
 			if config.Verbose {
-				fmt.Printf("Completed ping to %s\n", target)
+				fmt.Printf("Completed %s ping to %s\n", probe.mode, probe.target)
+				if result := results[idx]; result.Received > 0 {
+					minKm, maxKm := estimateDistance(result.AvgRTT)
+					fmt.Printf("Estimated distance to %s: %.0f-%.0f km\n", probe.target, minKm, maxKm)
+				}
 			}
-		}(i, target)
+		}(i, probe)
 	}
 
 	wg.Wait()
 	return results
 }
 
-func pingTarget(ctx context.Context, target string, config *PingConfig) PingResult {
-	result := PingResult{
-		Target: target,
-		RTTs:   make([]time.Duration, 0, config.Count),
-	}
+// prober sends one probe and waits up to timeout for its reply
+type prober interface {
+	ping(timeout time.Duration) (time.Duration, error)
+}
+
+func pingTarget(ctx context.Context, probe pingProbe, config *PingConfig) PingResult {
+	result := newPingResult(probe, config)
+	target := probe.target
 
-	ipAddr, err := net.ResolveIPAddr("ip4", target)
+	ipAddr, err := net.ResolveIPAddr(resolveNetwork(config.IPv6), target)
 	if err != nil {
 		result.Errors = append(result.Errors, fmt.Errorf("resolving address: %w", err))
+		result.Sent = config.Count
 		result.Lost = config.Count
 		return result
 	}
 
-	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Errorf("creating ICMP connection: %w", err))
-		result.Lost = config.Count
-		return result
+	result.Address = ipAddr.String()
+	if config.FlagPrivate && net.ParseIP(target) == nil && isPrivateAddr(ipAddr.IP) {
+		result.PrivateAddress = true
 	}
 
-	defer func() {
-		if err := conn.Close(); err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("closing connection: %w", err))
+	var session prober
+	if probe.mode == pingModeTCP {
+		session = &tcpProber{addr: net.JoinHostPort(ipAddr.String(), strconv.Itoa(config.Port))}
+	} else {
+		v6 := ipAddr.IP.To4() == nil
+		listenNetwork, listenAddr := "ip4:icmp", net.IPv4zero
+		if v6 {
+			listenNetwork, listenAddr = "ip6:ipv6-icmp", net.IPv6unspecified
+		}
+		conn, err := net.ListenIP(listenNetwork, &net.IPAddr{IP: listenAddr})
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("creating ICMP connection: %w", err))
+			result.Sent = config.Count
+			result.Lost = config.Count
+			return result
 		}
-	}()
 
-	session := &pingSession{
-		conn:   conn,
-		id:     os.Getpid() & 0xffff,
-		seq:    1,
-		target: ipAddr.String(), // 使用解析后的IP地址
+		defer func() {
+			if err := conn.Close(); err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("closing connection: %w", err))
+			}
+		}()
+
+		if config.DSCP > 0 {
+			if err := setDSCP(conn, v6, config.DSCP); err != nil {
+				// Still measure latency, just without the QoS marking
+				fmt.Fprintf(os.Stderr, "Warning: %s: %v, sending unmarked probes\n", target, err)
+			}
+		}
+
+		if config.DontFrag {
+			err := errors.New("--df only applies to IPv4 targets")
+			if !v6 {
+				err = setDontFragment(conn)
+			}
+			if err != nil {
+				result.Errors = append(result.Errors, err)
+				result.Sent = config.Count
+				result.Lost = config.Count
+				return result
+			}
+		}
+
+		if config.TTL > 0 {
+			if err := setTTL(conn, v6, config.TTL); err != nil {
+				result.Errors = append(result.Errors, err)
+				result.Sent = config.Count
+				result.Lost = config.Count
+				return result
+			}
+		}
+
+		icmpSession := newPingSession(conn, ipAddr.String(), v6) // 使用解析后的IP地址
+		icmpSession.size = config.Size
+		if config.InFlight > 1 {
+			pingPipelined(ctx, icmpSession, config, &result)
+			result.calculateStats()
+			return result
+		}
+		session = icmpSession
+	}
+
+	var rto *stats.RTOEstimator
+	if config.AdaptiveTimeout {
+		rto = stats.NewRTOEstimator(config.Timeout, minAdaptiveTimeout,
+			maxAdaptiveTimeoutFactor*config.Timeout)
 	}
 
+	goodStreak := 0
 	for i := 0; i < config.Count; i++ {
 		select {
 		case <-ctx.Done():
-			result.Errors = append(result.Errors, ctx.Err())
+			result.Cancelled = true
+			result.calculateStats()
 			return result
 		default:
-			rtt, err := session.ping(config.Timeout)
+			timeout := config.Timeout
+			if rto != nil {
+				timeout = rto.Timeout()
+			}
+			rtt, err := session.ping(timeout)
+			result.Sent++
+			if rto != nil {
+				if err == nil {
+					rto.Update(rtt)
+				} else if isTimeout(err) {
+					rto.Backoff()
+				}
+			}
 			if err != nil {
 				if config.Verbose {
-					fmt.Printf("Ping %s failed: %v\n", target, err)
+					fmt.Printf("Ping %s failed: %v\n", result.label(), err)
 				}
 				result.Lost++
 				result.Errors = append(result.Errors, err)
 			} else {
-				result.RTTs = append(result.RTTs, rtt)
+				result.record(rtt)
+				ttl := ""
+				if icmpSession, ok := session.(*pingSession); ok && icmpSession.lastTTL > 0 {
+					result.ReplyTTL = icmpSession.lastTTL
+					ttl = fmt.Sprintf(" TTL = %d", icmpSession.lastTTL)
+				}
 				if config.Verbose {
-					fmt.Printf("Ping %s: RTT = %v\n", target, rtt)
+					fmt.Printf("Ping %s: RTT = %v%s\n", result.label(), rtt, ttl)
 				}
 			}
-			session.seq++
-			time.Sleep(time.Second)
+
+			if err == nil && rtt < config.GoodRTT {
+				goodStreak++
+			} else {
+				goodStreak = 0
+			}
+			if i == config.Count-1 {
+				continue // No pause after the last probe
+			}
+			if config.StopOnGood && result.Lost == 0 && goodStreak >= config.GoodReplies {
+				result.StoppedEarly = true
+				result.calculateStats()
+				return result
+			}
+			_ = sleepCtx(ctx, config.Interval) // Cancellation is handled at the top of the loop
 		}
 	}
 
@@ -196,13 +668,120 @@ func pingTarget(ctx context.Context, target string, config *PingConfig) PingResu
 	return result
 }
 
+// setDSCP marks every packet sent on conn with a DSCP value. The code point
+// is the upper six bits of the IPv4 TOS / IPv6 traffic class byte.
+func setDSCP(conn *net.IPConn, v6 bool, dscp int) error {
+	if err := setTOS(conn, v6, dscp<<2); err != nil {
+		return fmt.Errorf("setting DSCP %d: %w", dscp, err)
+	}
+	return nil
+}
+
+// setTOS sets the IPv4 TOS (IPv6 traffic class) byte of every packet sent
+// on conn. It is a variable so tests can see the byte without a raw socket.
+var setTOS = func(conn *net.IPConn, v6 bool, tos int) error {
+	if v6 {
+		return ipv6.NewPacketConn(conn).SetTrafficClass(tos)
+	}
+	return ipv4.NewPacketConn(conn).SetTOS(tos)
+}
+
+// isTimeout reports whether a probe failed because its deadline passed
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 func (s *pingSession) ping(timeout time.Duration) (time.Duration, error) {
+	// 在发送前刷新任何待处理的响应
+	if err := s.conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return 0, fmt.Errorf("setting flush deadline: %w", err)
+	}
+	flush := make([]byte, s.bufferSize())
+	for {
+		_, _, err := s.conn.ReadFrom(flush)
+		if err != nil {
+			break
+		}
+	}
+
+	seq, err := s.send()
+	if err != nil {
+		return 0, err
+	}
+	defer delete(s.sent, seq)
+
+	// Skip packets meant for someone else until the reply arrives or the
+	// deadline passes; only seq is outstanding, so any match answers it
+	deadline := time.Now().Add(timeout)
+	for {
+		_, rtt, err := s.receive(deadline)
+		if errors.Is(err, errWrongReply) {
+			continue
+		}
+		return rtt, err
+	}
+}
+
+// newPingSession prepares an echo session to target over a raw ICMP conn
+func newPingSession(conn *net.IPConn, target string, v6 bool) *pingSession {
+	s := &pingSession{
+		conn:   conn,
+		id:     os.Getpid() & 0xffff,
+		target: target,
+		v6:     v6,
+		size:   defaultPayloadSize,
+		sent:   make(map[int]time.Time),
+	}
+	// Without control messages replies still arrive, just with no TTL
+	if v6 {
+		s.p6 = ipv6.NewPacketConn(conn)
+		_ = s.p6.SetControlMessage(ipv6.FlagHopLimit, true)
+	} else {
+		s.p4 = ipv4.NewPacketConn(conn)
+		_ = s.p4.SetControlMessage(ipv4.FlagTTL, true)
+	}
+	return s
+}
+
+// read receives one ICMP message, its source and the TTL (IPv6 hop limit)
+// it arrived with, or 0 when the platform does not report one
+func (s *pingSession) read(b []byte) (int, net.Addr, int, error) {
+	if s.v6 {
+		n, cm, peer, err := s.p6.ReadFrom(b)
+		if cm == nil {
+			return n, peer, 0, err
+		}
+		return n, peer, cm.HopLimit, err
+	}
+	n, cm, peer, err := s.p4.ReadFrom(b)
+	if cm == nil {
+		return n, peer, 0, err
+	}
+	return n, peer, cm.TTL, err
+}
+
+// bufferSize is large enough for an Ethernet frame or for an echo of the
+// session's payload, whichever is larger
+func (s *pingSession) bufferSize() int {
+	return max(1500, s.size+mtuProbeHeader)
+}
+
+// send transmits the next echo request and returns its sequence number
+func (s *pingSession) send() (int, error) {
+	s.seq = (s.seq + 1) & 0xffff // The ICMP sequence field is 16 bits
+
 	// 生成随机数据作为 payload
-	payload := make([]byte, 56) // 标准 ping 使用 56 字节
+	payload := make([]byte, s.size)
 	rand.Read(payload)
 
+	echoType := icmp.Type(ipv4.ICMPTypeEcho)
+	if s.v6 {
+		echoType = ipv6.ICMPTypeEchoRequest
+	}
+
 	msg := icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
+		Type: echoType,
 		Code: 0,
 		Body: &icmp.Echo{
 			ID:   s.id,
@@ -216,112 +795,209 @@ func (s *pingSession) ping(timeout time.Duration) (time.Duration, error) {
 		return 0, fmt.Errorf("marshaling ICMP message: %w", err)
 	}
 
-	// 在发送前刷新任何待处理的响应
-	if err := s.conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
-		return 0, fmt.Errorf("setting flush deadline: %w", err)
-	}
-	for {
-		_, _, err := s.conn.ReadFrom(make([]byte, 1500))
-		if err != nil {
-			break
-		}
-	}
-
-	start := time.Now()
+	s.sent[s.seq] = time.Now()
 	_, err = s.conn.WriteTo(msgBytes, &net.IPAddr{IP: net.ParseIP(s.target)})
 	if err != nil {
+		delete(s.sent, s.seq)
 		return 0, fmt.Errorf("sending ICMP message: %w", err)
 	}
+	return s.seq, nil
+}
+
+// receive reads one ICMP message, waiting until deadline, and returns the
+// sequence number and RTT of the outstanding probe it answers
+func (s *pingSession) receive(deadline time.Time) (int, time.Duration, error) {
+	replyType := icmp.Type(ipv4.ICMPTypeEchoReply)
+	proto := protocolICMP
+	if s.v6 {
+		replyType = ipv6.ICMPTypeEchoReply
+		proto = protocolICMPv6
+	}
 
-	if err := s.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
-		return 0, fmt.Errorf("setting read deadline: %w", err)
+	if err := s.conn.SetReadDeadline(deadline); err != nil {
+		return 0, 0, fmt.Errorf("setting read deadline: %w", err)
 	}
 
-	reply := make([]byte, 1500)
-	n, _, err := s.conn.ReadFrom(reply)
+	reply := make([]byte, s.bufferSize())
+	n, peer, ttl, err := s.read(reply)
 	if err != nil {
-		return 0, fmt.Errorf("reading ICMP reply: %w", err)
+		return 0, 0, fmt.Errorf("reading ICMP reply: %w", err)
 	}
+	received := time.Now()
 
-	rm, err := icmp.ParseMessage(protocolICMP, reply[:n])
+	rm, err := icmp.ParseMessage(proto, reply[:n])
 	if err != nil {
-		return 0, fmt.Errorf("parsing ICMP reply: %w", err)
+		return 0, 0, fmt.Errorf("parsing ICMP reply: %w", err)
+	}
+
+	// A router that dropped a probe for running out of TTL answers from
+	// its own address, quoting the probe
+	if exceeded, ok := rm.Body.(*icmp.TimeExceeded); ok {
+		id, seq, ok := quotedEcho(exceeded.Data, s.v6)
+		if _, outstanding := s.sent[seq]; !ok || id != s.id || !outstanding {
+			return 0, 0, errWrongReply
+		}
+		start := s.sent[seq]
+		delete(s.sent, seq)
+		return seq, received.Sub(start), &timeExceededError{seq: seq, router: peer.String()}
+	}
+
+	if from, ok := peer.(*net.IPAddr); ok && !from.IP.Equal(net.ParseIP(s.target)) {
+		return 0, 0, errWrongReply
 	}
 
 	switch rm.Type {
-	case ipv4.ICMPTypeEchoReply:
+	case replyType:
 		echo, ok := rm.Body.(*icmp.Echo)
 		if !ok {
-			return 0, errors.New("invalid ICMP echo reply")
+			return 0, 0, errors.New("invalid ICMP echo reply")
 		}
-		if echo.ID != s.id || echo.Seq != s.seq {
-			return 0, errors.New("received wrong ICMP reply")
+		start, outstanding := s.sent[echo.Seq]
+		if echo.ID != s.id || !outstanding {
+			return 0, 0, errWrongReply
 		}
-		return time.Since(start), nil
+		delete(s.sent, echo.Seq)
+		s.lastTTL = ttl
+		return echo.Seq, received.Sub(start), nil
 	default:
-		return 0, fmt.Errorf("received ICMP message of unexpected type: %v", rm.Type)
+		return 0, 0, fmt.Errorf("%w: unexpected type %v", errWrongReply, rm.Type)
 	}
 }
 
+// calculateStats derives Min/Avg/Max and Jitter from the recorded replies.
+// With no replies all are zero (callers check Received before showing them);
+// with a single reply Min/Avg/Max equal that RTT and Jitter is zero.
 func (r *PingResult) calculateStats() {
-	if len(r.RTTs) == 0 {
+	if r.running != nil {
+		r.MinRTT = r.running.Min()
+		r.MaxRTT = r.running.Max()
+		r.AvgRTT = r.running.Mean()
+		r.RTTs = r.reservoir.Values()
+		if r.Received > 1 {
+			r.Jitter = r.jitterTotal / time.Duration(r.Received-1)
+		}
 		return
 	}
 
-	r.MinRTT = r.RTTs[0]
-	r.MaxRTT = r.RTTs[0]
-	var total time.Duration
+	r.MinRTT, r.AvgRTT, r.MaxRTT, r.Jitter = ComputeRTTStats(r.RTTs)
+}
 
-	for _, rtt := range r.RTTs {
-		total += rtt
-		if rtt < r.MinRTT {
-			r.MinRTT = rtt
-		}
-		if rtt > r.MaxRTT {
-			r.MaxRTT = rtt
-		}
-	}
-	r.AvgRTT = total / time.Duration(len(r.RTTs))
+// ComputeRTTStats returns the minimum, mean, maximum and jitter (mean
+// difference between consecutive samples) of a series of round-trip times,
+// computed exactly as the ping results do. All are zero for an empty series.
+func ComputeRTTStats(rtts []time.Duration) (minRTT, avgRTT, maxRTT, jitter time.Duration) {
+	return stats.Min(rtts), stats.Mean(rtts), stats.Max(rtts), stats.Jitter(rtts)
 }
 
-func printResults(results []PingResult) {
-	fmt.Println("\nPING STATISTICS")
-	fmt.Println(strings.Repeat("=", 60))
-	fmt.Printf("%-20s %10s %10s %10s %12s\n", "TARGET", "MIN", "AVG", "MAX", "LOSS")
-	fmt.Println(strings.Repeat("-", 60))
+func printResults(w io.Writer, results []PingResult, color bool) {
+	fmt.Fprintln(w, "\nPING STATISTICS")
+	fmt.Fprintln(w, strings.Repeat("=", 88))
+	fmt.Fprintf(w, "  %-18s %10s %10s %10s %10s %10s %12s %5s\n", "TARGET", "MIN", "AVG", "MAX", "P95", "JITTER", "LOSS", "TTL")
+	fmt.Fprintln(w, strings.Repeat("-", 88))
 
 	for _, result := range results {
-		if len(result.RTTs) == 0 {
-			fmt.Printf("%-20s %10s %10s %10s %11d%%\n",
-				result.Target,
+		glyph := statusGlyph(classifyResult(result), color)
+		if result.Received == 0 {
+			fmt.Fprintf(w, "%s %-18s %10s %10s %10s %10s %10s %11d%% %5s\n",
+				glyph,
+				result.label(),
+				"N/A",
 				"N/A",
 				"N/A",
 				"N/A",
-				100)
+				"N/A",
+				100,
+				"N/A")
 
 			if len(result.Errors) > 0 {
-				fmt.Printf("  Errors:\n")
+				fmt.Fprintf(w, "  Errors:\n")
 				for _, err := range result.Errors {
-					fmt.Printf("  - %v\n", err)
+					fmt.Fprintf(w, "  - %v\n", err)
 				}
 			}
 		} else {
-			lossPercent := float64(result.Lost) * 100 / float64(len(result.RTTs)+result.Lost)
+			lossPercent := result.lossPercent()
 
 			// 格式化延迟值，统一使用毫秒为单位
 			_min := float64(result.MinRTT.Microseconds()) / 1000
 			_avg := float64(result.AvgRTT.Microseconds()) / 1000
 			_max := float64(result.MaxRTT.Microseconds()) / 1000
+			p95 := float64(result.Percentile(95).Microseconds()) / 1000
+			jitter := float64(result.Jitter.Microseconds()) / 1000
+			ttl := "-" // TCP probes and platforms that do not report it
+			if result.ReplyTTL > 0 {
+				ttl = strconv.Itoa(result.ReplyTTL)
+			}
 
-			fmt.Printf("%-20s %9.1fms %9.1fms %9.1fms %10.1f%%\n",
-				result.Target,
+			fmt.Fprintf(w, "%s %-18s %9.1fms %9.1fms %9.1fms %9.1fms %9.1fms %10.1f%% %5s\n",
+				glyph,
+				result.label(),
 				_min,
 				_avg,
 				_max,
-				lossPercent)
+				p95,
+				jitter,
+				lossPercent,
+				ttl)
+		}
+
+		if result.Cancelled {
+			fmt.Fprintf(w, "  Cut short after %d probes\n", result.Sent)
+		}
+		if result.StoppedEarly {
+			fmt.Fprintf(w, "  Stopped early after %d good replies\n", result.Received)
+		}
+		if result.PrivateAddress {
+			fmt.Fprintf(w, "  Warning: resolved to private/bogon address %s\n", result.Address)
 		}
 	}
-	fmt.Println(strings.Repeat("=", 60))
+
+	if summary := summarize(results); len(results) > 1 && summary.Reachable > 0 {
+		fmt.Fprintln(w, strings.Repeat("-", 88))
+		fmt.Fprintf(w, "Average RTT: %.1fms per target, %.1fms per reply\n",
+			float64(summary.SimpleAvg.Microseconds())/1000,
+			float64(summary.WeightedAvg.Microseconds())/1000)
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 88))
+}
+
+// pingSummary aggregates latency across all targets of a run
+type pingSummary struct {
+	Reachable int // Targets with at least one reply
+
+	// SimpleAvg is the mean of the per-target averages, so every reachable
+	// target counts equally. WeightedAvg is the mean over every individual
+	// reply, so targets that lost fewer packets weigh more. The two differ
+	// when loss is uneven across targets.
+	SimpleAvg   time.Duration
+	WeightedAvg time.Duration
 }
 
-const protocolICMP = 1
+func summarize(results []PingResult) pingSummary {
+	var summary pingSummary
+	var avgs []time.Duration
+	var weightedTotal time.Duration
+	var replies int
+
+	for _, result := range results {
+		if result.Received == 0 {
+			continue
+		}
+		summary.Reachable++
+		avgs = append(avgs, result.AvgRTT)
+		weightedTotal += result.AvgRTT * time.Duration(result.Received)
+		replies += result.Received
+	}
+
+	summary.SimpleAvg = stats.Mean(avgs)
+	if replies > 0 {
+		summary.WeightedAvg = weightedTotal / time.Duration(replies)
+	}
+	return summary
+}
+
+// IANA protocol numbers for parsing ICMP replies
+const (
+	protocolICMP   = 1
+	protocolICMPv6 = 58
+)
@@ -0,0 +1,396 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"speedgo/commands"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func ms(values ...float64) []time.Duration {
+	rtts := make([]time.Duration, len(values))
+	for i, v := range values {
+		rtts[i] = time.Duration(v * float64(time.Millisecond))
+	}
+	return rtts
+}
+
+func TestCalculateStats(t *testing.T) {
+	tests := []struct {
+		name                  string
+		rtts                  []time.Duration
+		min, avg, max, jitter time.Duration
+	}{
+		{
+			name: "no replies leaves everything zero",
+		},
+		{
+			name:   "single reply",
+			rtts:   ms(42),
+			min:    42 * time.Millisecond,
+			avg:    42 * time.Millisecond,
+			max:    42 * time.Millisecond,
+			jitter: 0,
+		},
+		{
+			name:   "known series",
+			rtts:   ms(10, 30, 20, 40),
+			min:    10 * time.Millisecond,
+			avg:    25 * time.Millisecond,
+			max:    40 * time.Millisecond,
+			jitter: 50 * time.Millisecond / 3, // (20 + 10 + 20) / 3
+		},
+		{
+			name:   "constant RTT has no jitter",
+			rtts:   ms(15, 15, 15),
+			min:    15 * time.Millisecond,
+			avg:    15 * time.Millisecond,
+			max:    15 * time.Millisecond,
+			jitter: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := PingResult{RTTs: tt.rtts, Received: len(tt.rtts)}
+			result.calculateStats()
+
+			if result.MinRTT != tt.min || result.AvgRTT != tt.avg || result.MaxRTT != tt.max {
+				t.Errorf("min/avg/max = %v/%v/%v, want %v/%v/%v",
+					result.MinRTT, result.AvgRTT, result.MaxRTT, tt.min, tt.avg, tt.max)
+			}
+			if result.Jitter != tt.jitter {
+				t.Errorf("Jitter = %v, want %v", result.Jitter, tt.jitter)
+			}
+		})
+	}
+}
+
+func TestCalculateStatsStreamingMatchesExact(t *testing.T) {
+	rtts := ms(12, 18, 9, 25, 14)
+	streaming := newPingResult(pingProbe{target: "example.com", mode: pingModeICMP},
+		&PingConfig{StreamingStats: true})
+	exact := newPingResult(pingProbe{target: "example.com", mode: pingModeICMP},
+		&PingConfig{Count: len(rtts)})
+	for _, rtt := range rtts {
+		streaming.record(rtt)
+		exact.record(rtt)
+	}
+	streaming.calculateStats()
+	exact.calculateStats()
+
+	if streaming.MinRTT != exact.MinRTT || streaming.MaxRTT != exact.MaxRTT || streaming.Jitter != exact.Jitter {
+		t.Errorf("streaming min/max/jitter = %v/%v/%v, exact %v/%v/%v",
+			streaming.MinRTT, streaming.MaxRTT, streaming.Jitter, exact.MinRTT, exact.MaxRTT, exact.Jitter)
+	}
+	if diff := (streaming.AvgRTT - exact.AvgRTT).Abs(); diff > time.Microsecond {
+		t.Errorf("streaming avg = %v, exact %v", streaming.AvgRTT, exact.AvgRTT)
+	}
+}
+
+func TestJitterInPingOutputs(t *testing.T) {
+	result := PingResult{Target: "1.1.1.1", Mode: pingModeICMP, RTTs: ms(10, 30, 20, 40), Sent: 4, Received: 4}
+	result.calculateStats() // Jitter 16.667ms
+
+	tests := []struct {
+		encoder Encoder
+		want    string
+	}{
+		{tableEncoder{}, "16.7ms"},
+		{markdownEncoder{}, "| 16.7ms |"},
+		{csvEncoder{}, "jitter_ms"},
+		{csvEncoder{}, ",16.7,"},
+		{jsonEncoder{}, `"jitter_ms": 16.666`},
+		{prometheusEncoder{}, `speedgo_ping_jitter_ms{target="1.1.1.1",mode="icmp"} 16.666`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := tt.encoder.EncodePing(&buf, &PingConfig{}, []PingResult{result}); err != nil {
+			t.Fatalf("%T: %v", tt.encoder, err)
+		}
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("%T output does not contain %q:\n%s", tt.encoder, tt.want, buf.String())
+		}
+	}
+}
+
+func TestLossPercent(t *testing.T) {
+	tests := []struct {
+		name                 string
+		sent, received, lost int
+		want                 float64
+	}{
+		{"nothing sent", 0, 0, 0, 0},
+		{"all replied", 10, 10, 0, 0},
+		{"some lost", 8, 6, 2, 25},
+		{"all lost", 4, 0, 4, 100},
+		// A run cut short only counts the probes that went out
+		{"cut short", 3, 2, 1, 100.0 / 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := PingResult{Sent: tt.sent, Received: tt.received, Lost: tt.lost}
+			if got := result.lossPercent(); got != tt.want {
+				t.Errorf("lossPercent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPingResultPercentile(t *testing.T) {
+	result := PingResult{RTTs: ms(40, 10, 30, 20, 50)}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{50, 30 * time.Millisecond},
+		{95, 48 * time.Millisecond}, // Between 40 and 50 at rank 3.8
+		{99, 49600 * time.Microsecond},
+		{100, 50 * time.Millisecond},
+		{150, 50 * time.Millisecond}, // Clamped to 100
+	}
+	for _, tt := range tests {
+		if got := result.Percentile(tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := result.RTTs[0]; got != 40*time.Millisecond {
+		t.Errorf("Percentile reordered RTTs, first is now %v", got)
+	}
+	if got := (&PingResult{}).Percentile(95); got != 0 {
+		t.Errorf("Percentile without replies = %v, want 0", got)
+	}
+}
+
+// TestPingIgnoresStrayPackets runs sessions against loopback side by side.
+// Each raw socket also sees its own echo requests and the other session's
+// traffic, none of which may count as a reply or a lost probe.
+func TestPingIgnoresStrayPackets(t *testing.T) {
+	requireRawICMP(t)
+
+	tests := []struct {
+		name     string
+		inFlight int
+	}{
+		{"sequential", 1},
+		{"pipelined", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &PingConfig{Count: 20, Timeout: time.Second, Interval: time.Millisecond, InFlight: tt.inFlight, Size: 56}
+			results := make([]PingResult, 3)
+			var wg sync.WaitGroup
+			for i := range results {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results[i] = pingTarget(context.Background(), pingProbe{target: "127.0.0.1", mode: pingModeICMP}, config)
+				}()
+			}
+			wg.Wait()
+
+			for i, result := range results {
+				if result.Received != config.Count || result.Lost != 0 || len(result.Errors) != 0 {
+					t.Errorf("session %d: %d of %d replies, %d lost: %v",
+						i, result.Received, config.Count, result.Lost, result.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestNewPingConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"zero count", []string{"--count=0"}, "invalid --count 0"},
+		{"negative count", []string{"--count=-4"}, "invalid --count -4"},
+		{"zero concurrency", []string{"--concurrency=0"}, "invalid --concurrency 0"},
+		{"zero in flight", []string{"--inflight=0"}, "--inflight must be at least 1"},
+		{"invalid fail-on", []string{"--fail-on=slow"}, "invalid --fail-on \"slow\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(t, commands.PingCmd)
+			// Literal addresses keep target deduplication off the network
+			_, err := NewPingConfig(append([]string{"--targets=127.0.0.1"}, tt.args...))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewPingConfig(%q): %v", tt.args, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewPingConfig(%q) error = %v, want one containing %q", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDedupeTargets(t *testing.T) {
+	tests := []struct {
+		name           string
+		targets        []string
+		network        string
+		want           []string
+		wantDuplicates int
+	}{
+		{"same name", []string{"Localhost", "localhost"}, "ip", []string{"Localhost"}, 1},
+		{"name and its IPv4 address", []string{"localhost", "127.0.0.1"}, "ip", []string{"localhost"}, 1},
+		{"IPv6 spellings", []string{"::1", "0:0::1"}, "ip6", []string{"::1"}, 1},
+		// localhost resolves to ::1 or not at all on IPv6, never to 127.0.0.1
+		{"IPv4 address under --ipv6", []string{"localhost", "127.0.0.1"}, "ip6", []string{"localhost", "127.0.0.1"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, duplicates := dedupeTargets(tt.targets, tt.network)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") || duplicates != tt.wantDuplicates {
+				t.Errorf("dedupeTargets(%q, %q) = %q, %d, want %q, %d",
+					tt.targets, tt.network, got, duplicates, tt.want, tt.wantDuplicates)
+			}
+		})
+	}
+}
+
+func TestSetDSCP(t *testing.T) {
+	defer func(saved func(*net.IPConn, bool, int) error) { setTOS = saved }(setTOS)
+
+	tests := []struct {
+		name    string
+		v6      bool
+		dscp    int
+		wantTOS int
+	}{
+		{"unmarked", false, 0, 0},
+		{"expedited forwarding", false, 46, 184},
+		{"class selector 1 over IPv6", true, 8, 32},
+		{"largest code point", false, maxDSCP, 252},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTOS, gotV6 := -1, !tt.v6
+			setTOS = func(conn *net.IPConn, v6 bool, tos int) error {
+				gotTOS, gotV6 = tos, v6
+				return nil
+			}
+			if err := setDSCP(nil, tt.v6, tt.dscp); err != nil {
+				t.Fatal(err)
+			}
+			if gotTOS != tt.wantTOS || gotV6 != tt.v6 {
+				t.Errorf("setDSCP(%v, %d) wrote %d (v6 %v), want %d", tt.v6, tt.dscp, gotTOS, gotV6, tt.wantTOS)
+			}
+		})
+	}
+
+	setTOS = func(*net.IPConn, bool, int) error { return errors.New("not permitted") }
+	if err := setDSCP(nil, false, 46); err == nil || !strings.Contains(err.Error(), "setting DSCP 46") {
+		t.Errorf("error = %v, want one naming the DSCP value", err)
+	}
+}
+
+func TestPingPayloadSizeLoopback(t *testing.T) {
+	requireRawICMP(t)
+
+	tests := []struct {
+		name     string
+		size     int
+		dontFrag bool
+	}{
+		{"empty payload", 0, false},
+		{"a few bytes", 4, false},
+		{"fills an Ethernet MTU", 1472, true},
+		{"larger than the 1500-byte read buffer", 8000, false},
+		{"jumbo unfragmented on loopback", 8000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := pingTarget(context.Background(), pingProbe{target: "127.0.0.1", mode: pingModeICMP}, &PingConfig{
+				Count:    3,
+				Timeout:  time.Second,
+				Interval: 10 * time.Millisecond,
+				InFlight: 1,
+				Size:     tt.size,
+				DontFrag: tt.dontFrag,
+			})
+			if result.Received != 3 || result.Lost != 0 || len(result.Errors) != 0 {
+				t.Errorf("%d of 3 replies with %d-byte payloads, %d lost: %v",
+					result.Received, tt.size, result.Lost, result.Errors)
+			}
+		})
+	}
+}
+
+// requireRawICMPv6 skips tests that need a raw ICMPv6 socket and IPv6
+// loopback when either is unavailable
+func requireRawICMPv6(t *testing.T) {
+	t.Helper()
+	conn, err := net.ListenIP("ip6:ipv6-icmp", &net.IPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("raw ICMPv6 sockets or IPv6 loopback unavailable: %v", err)
+	}
+	conn.Close()
+}
+
+func TestPingIPv6Loopback(t *testing.T) {
+	requireRawICMPv6(t)
+
+	tests := []struct {
+		name     string
+		inFlight int
+	}{
+		{"sequential", 1},
+		{"pipelined", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := pingTarget(context.Background(), pingProbe{target: "::1", mode: pingModeICMP}, &PingConfig{
+				Count:    4,
+				Timeout:  time.Second,
+				Interval: 10 * time.Millisecond,
+				InFlight: tt.inFlight,
+				Size:     defaultPayloadSize,
+				IPv6:     true,
+			})
+			if result.Received != 4 || result.Lost != 0 || len(result.Errors) != 0 {
+				t.Errorf("%d of 4 replies from ::1, %d lost: %v", result.Received, result.Lost, result.Errors)
+			}
+		})
+	}
+}
+
+func TestSplitTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets string
+		sep     string
+		want    []string
+	}{
+		{"default separator", "a.com,b.com,1.1.1.1", ",", []string{"a.com", "b.com", "1.1.1.1"}},
+		{"custom separator", "a.com;b.com;::1", ";", []string{"a.com", "b.com", "::1"}},
+		{"spaces around the separator", "a.com | b.com", "|", []string{"a.com", "b.com"}},
+		{"custom separator leaves commas", "a.com,b.com;c.com", ";", []string{"a.com,b.com", "c.com"}},
+		{"surrounding whitespace", "  a.com ,\tb.com\n", ",", []string{"a.com", "b.com"}},
+		{"whitespace separates too", "a.com b.com,c.com\nd.com", ",", []string{"a.com", "b.com", "c.com", "d.com"}},
+		{"empty fields", ",,a.com,, ,b.com,", ",", []string{"a.com", "b.com"}},
+		{"empty separator splits on whitespace", "a.com,b.com c.com", "", []string{"a.com,b.com", "c.com"}},
+		{"nothing but separators", " ; ;; ", ";", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitTargets(tt.targets, tt.sep)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitTargets(%q, %q) = %q, want %q", tt.targets, tt.sep, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package core progress.go
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// rollingWindow is how far back the progress line looks for the current
+// speed, so it follows sudden changes that the overall average smooths out
+const rollingWindow = 3 * time.Second
+
+// progressReading is the byte counter and elapsed time at one tick
+type progressReading struct {
+	elapsed time.Duration
+	bytes   int64
+}
+
+// rollingSpeed computes the speed over the readings of the last window
+type rollingSpeed struct {
+	window   time.Duration
+	readings []progressReading
+}
+
+// add records a reading and returns the speed in Mbps since the oldest
+// reading within the window, or since the start for the first one
+func (r *rollingSpeed) add(reading progressReading) float64 {
+	// The measurement window restarts when a warmup ends
+	if n := len(r.readings); n > 0 && reading.elapsed < r.readings[n-1].elapsed {
+		r.readings = r.readings[:0]
+	}
+	r.readings = append(r.readings, reading)
+	for len(r.readings) > 2 && reading.elapsed-r.readings[1].elapsed >= r.window {
+		r.readings = r.readings[1:]
+	}
+
+	if len(r.readings) == 1 {
+		return mbps(reading.bytes, reading.elapsed)
+	}
+	oldest := r.readings[0]
+	return mbps(reading.bytes-oldest.bytes, reading.elapsed-oldest.elapsed)
+}
+
+// progressTicker rewrites a live "current speed" line on stdout every second
+// until it is stopped. The current speed covers the last rollingWindow; the
+// average since the start is shown next to it.
+type progressTicker struct {
+	label   string
+	counter *int64
+	elapsed func() time.Duration
+	unit    string
+	stop    chan struct{}
+	done    chan struct{}
+	printed bool
+}
+
+// startProgress starts the verbose progress line; when verbose is off the
+// returned ticker does nothing
+func startProgress(verbose bool, label string, counter *int64, elapsed func() time.Duration, unit string) *progressTicker {
+	p := &progressTicker{
+		label:   label,
+		counter: counter,
+		elapsed: elapsed,
+		unit:    unit,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if verbose {
+		go p.run()
+	} else {
+		close(p.done)
+	}
+	return p
+}
+
+func (p *progressTicker) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	rolling := &rollingSpeed{window: rollingWindow}
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			reading := progressReading{elapsed: p.elapsed(), bytes: atomic.LoadInt64(p.counter)}
+			current := rolling.add(reading)
+			average := mbps(reading.bytes, reading.elapsed)
+			fmt.Printf("\r%s: %s (average %s)\033[K", p.label, formatSpeed(current, p.unit), formatSpeed(average, p.unit))
+			p.printed = true
+		}
+	}
+}
+
+// Stop waits for the ticker goroutine to exit and clears the progress line,
+// so whatever is printed next starts on a clean line
+func (p *progressTicker) Stop() {
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	<-p.done
+	if p.printed {
+		fmt.Print("\r\033[K")
+		p.printed = false
+	}
+}
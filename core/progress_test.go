@@ -0,0 +1,108 @@
+package core
+
+import (
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+func TestProgressStop(t *testing.T) {
+	tests := []struct {
+		name      string
+		verbose   bool
+		run       time.Duration // How long the ticker runs before Stop
+		wantLine  bool
+		wantClear bool
+	}{
+		{"quiet prints nothing", false, 0, false, false},
+		{"stopped before the first tick", true, 0, false, false},
+		{"clears the line it printed", true, 1200 * time.Millisecond, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := int64(1_000_000)
+			out := captureStdout(t, func() {
+				progress := startProgress(tt.verbose, "Current speed", &counter,
+					func() time.Duration { return time.Second }, "mbps")
+				time.Sleep(tt.run)
+				progress.Stop()
+				progress.Stop() // A second Stop, as the deferred one, is harmless
+			})
+
+			if got := strings.Contains(out, "Current speed: "); got != tt.wantLine {
+				t.Errorf("progress line printed = %v, want %v (output %q)", got, tt.wantLine, out)
+			}
+			if got := strings.HasSuffix(out, "\r\033[K"); got != tt.wantClear {
+				t.Errorf("line cleared at the end = %v, want %v (output %q)", got, tt.wantClear, out)
+			}
+		})
+	}
+}
+
+func TestRollingSpeed(t *testing.T) {
+	// 10 Mbps for 5s, then 40 Mbps; a 1.25MB second is 10 Mbps
+	var readings []progressReading
+	var bytes int64
+	for second := 1; second <= 8; second++ {
+		rate := int64(1_250_000)
+		if second > 5 {
+			rate = 5_000_000
+		}
+		bytes += rate
+		readings = append(readings, progressReading{elapsed: time.Duration(second) * time.Second, bytes: bytes})
+	}
+
+	tests := []struct {
+		wantMbps   float64
+		wantOldest time.Duration // Oldest reading kept afterwards
+	}{
+		{10, time.Second}, // The first reading is measured from the start
+		{10, time.Second},
+		{10, time.Second},
+		{10, time.Second},
+		{10, 2 * time.Second}, // The 1s reading fell out of the 3s window
+		{20, 3 * time.Second}, // One fast second of three
+		{30, 4 * time.Second},
+		{40, 5 * time.Second}, // Only the fast seconds are left
+	}
+	r := &rollingSpeed{window: 3 * time.Second}
+	for i, tt := range tests {
+		got := r.add(readings[i])
+		if math.Abs(got-tt.wantMbps) > 1e-9 {
+			t.Errorf("at %v: %v Mbps, want %v", readings[i].elapsed, got, tt.wantMbps)
+		}
+		if oldest := r.readings[0].elapsed; oldest != tt.wantOldest {
+			t.Errorf("at %v: oldest reading %v, want %v", readings[i].elapsed, oldest, tt.wantOldest)
+		}
+	}
+
+	// A warmup ending restarts the window, dropping every earlier reading
+	got := r.add(progressReading{elapsed: time.Second, bytes: 2_500_000})
+	if math.Abs(got-20) > 1e-9 || len(r.readings) != 1 {
+		t.Errorf("after a restart: %v Mbps over %d readings, want 20 Mbps over 1", got, len(r.readings))
+	}
+}
@@ -0,0 +1,112 @@
+// Package core prometheus.go
+package core
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// prometheusEncoder writes results in the Prometheus text exposition
+// format, e.g. for the node_exporter textfile collector when run from cron
+type prometheusEncoder struct{}
+
+// promLabelEscaper escapes label values as the exposition format requires
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promFamily writes the HELP and TYPE lines that introduce a gauge
+func promFamily(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+}
+
+// promSample writes one sample; labels are name/value pairs
+func promSample(w io.Writer, name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, `%s="%s"`, labels[i], promLabelEscaper.Replace(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(w, "%s %s\n", b.String(), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (prometheusEncoder) EncodePing(w io.Writer, config *PingConfig, results []PingResult) error {
+	writePingMetrics(w, results)
+	return nil
+}
+
+func (prometheusEncoder) EncodeDownload(w io.Writer, config *DownloadConfig, stats DownloadStats) error {
+	writeTransferMetrics(w, "download", stats.BytesReceived, stats.Duration.Seconds(), stats.Speed)
+	return nil
+}
+
+func (prometheusEncoder) EncodeUpload(w io.Writer, config *UploadConfig, stats UploadStats) error {
+	writeTransferMetrics(w, "upload", stats.BytesSent, stats.Duration.Seconds(), stats.Speed)
+	return nil
+}
+
+// writePingMetrics writes one sample per target for each ping metric. RTT
+// gauges are left out for targets that never replied rather than set to 0.
+func writePingMetrics(w io.Writer, results []PingResult) {
+	promFamily(w, "speedgo_ping_up", "Whether the target answered any probe.")
+	for _, result := range results {
+		up := 0.0
+		if result.Received > 0 {
+			up = 1
+		}
+		promSample(w, "speedgo_ping_up", up, "target", result.Target, "mode", result.Mode)
+	}
+
+	promFamily(w, "speedgo_ping_rtt_ms", "Average round-trip time in milliseconds.")
+	for _, result := range results {
+		if result.Received > 0 {
+			promSample(w, "speedgo_ping_rtt_ms", milliseconds(result.AvgRTT), "target", result.Target, "mode", result.Mode)
+		}
+	}
+
+	promFamily(w, "speedgo_ping_jitter_ms", "Mean difference between consecutive round-trip times in milliseconds.")
+	for _, result := range results {
+		if result.Received > 0 {
+			promSample(w, "speedgo_ping_jitter_ms", milliseconds(result.Jitter), "target", result.Target, "mode", result.Mode)
+		}
+	}
+
+	promFamily(w, "speedgo_packet_loss_ratio", "Share of probes that got no reply, from 0 to 1.")
+	for _, result := range results {
+		promSample(w, "speedgo_packet_loss_ratio", result.lossPercent()/100, "target", result.Target, "mode", result.Mode)
+	}
+}
+
+// writeTransferMetrics writes the metrics of a download or upload test
+func writeTransferMetrics(w io.Writer, direction string, bytes int64, seconds, speed float64) {
+	prefix := "speedgo_" + direction
+	promFamily(w, prefix+"_mbps", "Average "+direction+" speed in megabits per second.")
+	promSample(w, prefix+"_mbps", speed)
+	promFamily(w, prefix+"_bytes", "Bytes transferred during the "+direction+" test.")
+	promSample(w, prefix+"_bytes", float64(bytes))
+	promFamily(w, prefix+"_duration_seconds", "Duration of the "+direction+" test.")
+	promSample(w, prefix+"_duration_seconds", seconds)
+}
+
+// writeSpeedTestMetrics writes every metric of the combined test
+func writeSpeedTestMetrics(w io.Writer, report SpeedTestReport) {
+	writePingMetrics(w, []PingResult{report.Ping})
+	writeTransferMetrics(w, "download", report.Download.BytesReceived, report.Download.Duration.Seconds(), report.Download.Speed)
+	writeTransferMetrics(w, "upload", report.Upload.BytesSent, report.Upload.Duration.Seconds(), report.Upload.Speed)
+
+	// Loaded latency is only known for --bidirectional runs that got replies
+	if latency := report.Latency; latency != nil && latency.Idle.Received > 0 && latency.Loaded.Received > 0 {
+		promFamily(w, "speedgo_loaded_rtt_ms", "Average round-trip time while downloading and uploading, in milliseconds.")
+		promSample(w, "speedgo_loaded_rtt_ms", milliseconds(latency.Loaded.AvgRTT), "target", latency.Target)
+		promFamily(w, "speedgo_bufferbloat_ms", "Increase of the average round-trip time under load, in milliseconds.")
+		promSample(w, "speedgo_bufferbloat_ms", milliseconds(latency.Increase()), "target", latency.Target)
+	}
+}
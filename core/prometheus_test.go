@@ -0,0 +1,116 @@
+package core
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	promSampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{(.*)\})? (\S+)$`)
+	promLabel      = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)="((?:[^"\\]|\\.)*)"(,|$)`)
+)
+
+// parseExposition checks text against the Prometheus text format: every
+// sample belongs to the family whose HELP and TYPE lines came just before
+// it, and no family appears twice. It returns the samples keyed by name and
+// labels as written.
+func parseExposition(t *testing.T, text string) map[string]float64 {
+	t.Helper()
+	samples := make(map[string]float64)
+	seen := make(map[string]bool)
+	var family string
+	var typed bool
+	for i, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "# HELP "):
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) < 4 || fields[3] == "" {
+				t.Fatalf("line %d: HELP without text: %q", i+1, line)
+			}
+			family, typed = fields[2], false
+			if seen[family] {
+				t.Fatalf("line %d: family %s appears twice", i+1, family)
+			}
+			seen[family] = true
+		case strings.HasPrefix(line, "# TYPE "):
+			if line != "# TYPE "+family+" gauge" {
+				t.Fatalf("line %d: %q, want the gauge TYPE of %s", i+1, line, family)
+			}
+			typed = true
+		default:
+			m := promSampleLine.FindStringSubmatch(line)
+			if m == nil {
+				t.Fatalf("line %d: malformed sample %q", i+1, line)
+			}
+			if m[1] != family || !typed {
+				t.Fatalf("line %d: sample of %s outside its HELP and TYPE", i+1, m[1])
+			}
+			for labels := m[3]; labels != ""; {
+				l := promLabel.FindStringSubmatch(labels)
+				if l == nil {
+					t.Fatalf("line %d: malformed labels %q", i+1, m[3])
+				}
+				labels = labels[len(l[0]):]
+			}
+			value, err := strconv.ParseFloat(m[4], 64)
+			if err != nil {
+				t.Fatalf("line %d: value %q: %v", i+1, m[4], err)
+			}
+			samples[m[1]+m[2]] = value
+		}
+	}
+	return samples
+}
+
+func TestPrometheusPing(t *testing.T) {
+	results := []PingResult{
+		{Target: "example.com", Mode: pingModeICMP, Sent: 4, Received: 3, Lost: 1, AvgRTT: 12500 * time.Microsecond, Jitter: 2 * time.Millisecond},
+		{Target: `odd "host"\`, Mode: pingModeTCP, Sent: 4, Lost: 4},
+	}
+	var buf bytes.Buffer
+	if err := (prometheusEncoder{}).EncodePing(&buf, &PingConfig{}, results); err != nil {
+		t.Fatal(err)
+	}
+	samples := parseExposition(t, buf.String())
+
+	want := map[string]float64{
+		`speedgo_ping_up{target="example.com",mode="icmp"}`:             1,
+		`speedgo_ping_rtt_ms{target="example.com",mode="icmp"}`:         12.5,
+		`speedgo_ping_jitter_ms{target="example.com",mode="icmp"}`:      2,
+		`speedgo_packet_loss_ratio{target="example.com",mode="icmp"}`:   0.25,
+		`speedgo_ping_up{target="odd \"host\"\\",mode="tcp"}`:           0,
+		`speedgo_packet_loss_ratio{target="odd \"host\"\\",mode="tcp"}`: 1,
+	}
+	for key, value := range want {
+		if got, ok := samples[key]; !ok || got != value {
+			t.Errorf("%s = %v (present %v), want %v", key, got, ok, value)
+		}
+	}
+	if len(samples) != len(want) {
+		t.Errorf("%d samples, want %d; RTT gauges must be left out without replies:\n%s", len(samples), len(want), buf.String())
+	}
+}
+
+func TestPrometheusTransfer(t *testing.T) {
+	var buf bytes.Buffer
+	stats := DownloadStats{BytesReceived: 12_500_000, Duration: 2 * time.Second, Speed: 50}
+	if err := (prometheusEncoder{}).EncodeDownload(&buf, &DownloadConfig{}, stats); err != nil {
+		t.Fatal(err)
+	}
+	samples := parseExposition(t, buf.String())
+
+	want := map[string]float64{
+		"speedgo_download_mbps":             50,
+		"speedgo_download_bytes":            12_500_000,
+		"speedgo_download_duration_seconds": 2,
+	}
+	for key, value := range want {
+		if got := samples[key]; got != value {
+			t.Errorf("%s = %v, want %v", key, got, value)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package core quality.go
+package core
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"speedgo/commands"
+)
+
+// MOS thresholds for rating a connection for real-time audio. Toll-quality
+// calls score above 4; below about 3.6 many users are dissatisfied.
+const (
+	goodMOS = 4.0
+	fairMOS = 3.6
+)
+
+// QualityResult is the voice quality estimate for one ping target
+type QualityResult struct {
+	Ping   PingResult
+	MOS    float64 // Mean opinion score, 1 (bad) to 4.5 (best)
+	Rating string  // Good, Fair or Poor
+}
+
+// estimateMOS derives a VoIP mean opinion score from ping statistics with
+// the common simplification of the ITU-T G.107 E-model: jitter counts
+// double towards the delay, the R-factor loses a point for every 40ms up
+// to 160ms and then one per 10ms, and each percent of loss costs 2.5
+// points. R maps to MOS with the standard G.107 polynomial.
+func estimateMOS(avg, jitter time.Duration, lossPercent float64) float64 {
+	latency := float64(avg.Microseconds())/1000 + 2*float64(jitter.Microseconds())/1000 + 10
+
+	r := 93.2 - latency/40
+	if latency >= 160 {
+		r = 93.2 - (latency-120)/10
+	}
+	r -= 2.5 * lossPercent
+	r = max(0, min(100, r))
+
+	return 1 + 0.035*r + 0.000007*r*(r-60)*(100-r)
+}
+
+// rateMOS names the real-time audio quality of a MOS
+func rateMOS(mos float64) string {
+	switch {
+	case mos >= goodMOS:
+		return "Good"
+	case mos >= fairMOS:
+		return "Fair"
+	default:
+		return "Poor"
+	}
+}
+
+// newQualityResult scores the ping results of one target. A target that
+// never replied is rated Poor with the lowest score.
+func newQualityResult(result PingResult) QualityResult {
+	if result.Received == 0 {
+		return QualityResult{Ping: result, MOS: 1, Rating: rateMOS(1)}
+	}
+	mos := estimateMOS(result.AvgRTT, result.Jitter, result.lossPercent())
+	return QualityResult{Ping: result, MOS: mos, Rating: rateMOS(mos)}
+}
+
+func parseQualityConfig(args []string) (*PingConfig, error) {
+	cmd := commands.QualityCmd
+	if err := cmd.Parse(args); err != nil {
+		return nil, fmt.Errorf("parsing quality arguments: %w", err)
+	}
+	if err := applyFlagSources(cmd); err != nil {
+		return nil, err
+	}
+
+	config := &PingConfig{
+		Targets:     DefaultPingTargets,
+		Count:       cmd.Lookup("count").Value.(flag.Getter).Get().(int),
+		Timeout:     cmd.Lookup("timeout").Value.(flag.Getter).Get().(time.Duration),
+		Interval:    cmd.Lookup("interval").Value.(flag.Getter).Get().(time.Duration),
+		Concurrency: cmd.Lookup("concurrency").Value.(flag.Getter).Get().(int),
+		InFlight:    1,
+		Size:        defaultPayloadSize,
+		Verbose:     cmd.Lookup("verbose").Value.(flag.Getter).Get().(bool),
+		Mode:        pingModeICMP,
+	}
+
+	if targets := cmd.Lookup("targets").Value.String(); targets != "" {
+		config.Targets = splitTargets(targets, ",")
+	}
+	if len(config.Targets) == 0 {
+		return nil, errors.New("no valid targets provided")
+	}
+	config.Targets, config.Duplicates = dedupeTargets(config.Targets, resolveNetwork(config.IPv6))
+
+	// Jitter needs at least two replies
+	if config.Count < 2 {
+		return nil, errors.New("--count must be at least 2")
+	}
+	if config.Interval < minPingInterval {
+		return nil, fmt.Errorf("--interval must be at least %v", minPingInterval)
+	}
+	if config.Concurrency < 1 {
+		return nil, errors.New("--concurrency must be at least 1")
+	}
+	return config, nil
+}
+
+// RunQuality pings each target in a quick burst and rates the connection
+// for real-time audio from the latency, jitter and loss it sees
+func RunQuality(ctx context.Context, args []string) error {
+	config, err := parseQualityConfig(args)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Measuring call quality to %d targets (%d pings every %v)...\n",
+		len(config.Targets), config.Count, config.Interval)
+
+	pings := pingTargets(ctx, config)
+	results := make([]QualityResult, len(pings))
+	for i, result := range pings {
+		results[i] = newQualityResult(result)
+	}
+	printQualityResults(os.Stdout, results)
+	return nil
+}
+
+func printQualityResults(w io.Writer, results []QualityResult) {
+	fmt.Fprintln(w, "\nCALL QUALITY")
+	fmt.Fprintln(w, strings.Repeat("=", 72))
+	fmt.Fprintf(w, "%-20s %10s %10s %10s %8s %10s\n", "TARGET", "AVG", "JITTER", "LOSS", "MOS", "RATING")
+	fmt.Fprintln(w, strings.Repeat("-", 72))
+
+	worst := -1
+	for i, result := range results {
+		ping := result.Ping
+		if ping.Received == 0 {
+			fmt.Fprintf(w, "%-20s %10s %10s %9d%% %8s %10s\n",
+				ping.label(), "N/A", "N/A", 100, "N/A", result.Rating)
+		} else {
+			fmt.Fprintf(w, "%-20s %8.1fms %8.1fms %9.1f%% %8.2f %10s\n",
+				ping.label(),
+				float64(ping.AvgRTT.Microseconds())/1000,
+				float64(ping.Jitter.Microseconds())/1000,
+				ping.lossPercent(),
+				result.MOS,
+				result.Rating)
+		}
+		if worst < 0 || result.MOS < results[worst].MOS {
+			worst = i
+		}
+	}
+
+	fmt.Fprintln(w, strings.Repeat("=", 72))
+	if worst >= 0 {
+		// A call is only as good as the path it takes, so judge by the worst
+		fmt.Fprintf(w, "Real-time audio: %s (worst target: %s)\n",
+			results[worst].Rating, results[worst].Ping.label())
+	}
+}
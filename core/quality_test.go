@@ -0,0 +1,35 @@
+package core
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEstimateMOS(t *testing.T) {
+	tests := []struct {
+		name   string
+		avg    time.Duration
+		jitter time.Duration
+		loss   float64
+		want   float64
+	}{
+		{"ideal", 0, 0, 0, 4.404394368375},
+		{"typical", 20 * time.Millisecond, 5 * time.Millisecond, 0, 4.389098664},
+		{"just below 160ms effective latency", 149 * time.Millisecond, 0, 0, 4.319553277140625},
+		{"at 160ms effective latency", 150 * time.Millisecond, 0, 0, 4.318910784},
+		{"past 160ms effective latency", 200 * time.Millisecond, 0, 0, 4.172362984},
+		{"jitter counts double", 180 * time.Millisecond, 10 * time.Millisecond, 0, 4.172362984},
+		{"some loss", 20 * time.Millisecond, 5 * time.Millisecond, 10, 3.463089664},
+		{"total loss", 20 * time.Millisecond, 5 * time.Millisecond, 100, 1},
+		{"floor at 1", 2 * time.Second, 0, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := estimateMOS(tt.avg, tt.jitter, tt.loss)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("estimateMOS(%v, %v, %v) = %v, want %v", tt.avg, tt.jitter, tt.loss, got, tt.want)
+			}
+		})
+	}
+}
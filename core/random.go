@@ -0,0 +1,35 @@
+// Package core random.go
+package core
+
+import (
+	mathrand "math/rand"
+	"sync"
+	"time"
+)
+
+// rng is the package-level source for non-cryptographic randomness such as
+// worker-to-URL assignment and generated payloads. It is seeded from the
+// clock unless a test run fixes the seed for reproducibility.
+var (
+	rngMu sync.Mutex
+	rng   = mathrand.New(mathrand.NewSource(time.Now().UnixNano()))
+)
+
+// seedRNG makes all subsequent package-level random choices deterministic
+func seedRNG(seed int64) {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	rng = mathrand.New(mathrand.NewSource(seed))
+}
+
+func randPerm(n int) []int {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	return rng.Perm(n)
+}
+
+func randRead(p []byte) {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	rng.Read(p)
+}
@@ -0,0 +1,68 @@
+// Package core recovery.go
+package core
+
+import "time"
+
+// recoverySampleInterval is the throughput sampling resolution used when
+// watching for drops
+const recoverySampleInterval = 500 * time.Millisecond
+
+// RecoveryEvent describes one period during which throughput fell to zero
+type RecoveryEvent struct {
+	DroppedAt      time.Duration // Offset from test start of the first empty interval
+	RecoveredAfter time.Duration // Time until bytes flowed again
+	Recovered      bool          // False if the test ended while still dropped
+}
+
+type flowState int
+
+const (
+	flowWaiting flowState = iota // No bytes seen yet (connections still being set up)
+	flowRunning
+	flowDropped
+)
+
+// detectRecoveries walks the samples and reports every transition from
+// flowing traffic to zero throughput, along with how long it took to resume.
+// Intervals overlapping a pause are skipped, as no traffic is expected then.
+// When finished is set the transfer ended on its own, so a final stretch
+// without traffic is its end rather than a drop.
+func detectRecoveries(samples []Sample, finished bool) []RecoveryEvent {
+	var events []RecoveryEvent
+	var current RecoveryEvent
+	var prevOffset time.Duration
+	state := flowWaiting
+
+	for _, sample := range samples {
+		if sample.Paused {
+			prevOffset = sample.Offset
+			continue
+		}
+		switch state {
+		case flowWaiting:
+			if sample.Bytes > 0 {
+				state = flowRunning
+			}
+		case flowRunning:
+			if sample.Bytes == 0 {
+				current = RecoveryEvent{DroppedAt: prevOffset}
+				state = flowDropped
+			}
+		case flowDropped:
+			if sample.Bytes > 0 {
+				current.RecoveredAfter = prevOffset - current.DroppedAt
+				current.Recovered = true
+				events = append(events, current)
+				state = flowRunning
+			}
+		}
+		prevOffset = sample.Offset
+	}
+
+	if state == flowDropped && !finished {
+		current.RecoveredAfter = prevOffset - current.DroppedAt
+		events = append(events, current)
+	}
+
+	return events
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// samples builds one sample per recoverySampleInterval from byte counts,
+// marking the intervals listed in paused
+func samples(bytes []int64, paused ...int) []Sample {
+	result := make([]Sample, len(bytes))
+	for i, n := range bytes {
+		result[i] = Sample{Offset: time.Duration(i+1) * recoverySampleInterval, Bytes: n}
+	}
+	for _, i := range paused {
+		result[i].Paused = true
+	}
+	return result
+}
+
+func TestDetectRecoveries(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []Sample
+		finished bool
+		want     []RecoveryEvent
+	}{
+		{"steady", samples([]int64{0, 10, 10, 10}), false, nil},
+		{"recovered drop", samples([]int64{10, 0, 0, 10}), false, []RecoveryEvent{
+			{DroppedAt: 500 * time.Millisecond, RecoveredAfter: time.Second, Recovered: true},
+		}},
+		{"still dropped at the end", samples([]int64{10, 10, 0}), false, []RecoveryEvent{
+			{DroppedAt: time.Second, RecoveredAfter: 500 * time.Millisecond},
+		}},
+		{"single pass finished", samples([]int64{10, 10, 0}), true, nil},
+		{"single pass finished after a drop", samples([]int64{10, 0, 10, 0}), true, []RecoveryEvent{
+			{DroppedAt: 500 * time.Millisecond, RecoveredAfter: 500 * time.Millisecond, Recovered: true},
+		}},
+		{"paused", samples([]int64{10, 0, 0, 10}, 1, 2), false, nil},
+		{"paused until the end", samples([]int64{10, 0, 0}, 1, 2), false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectRecoveries(tt.samples, tt.finished)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectRecoveries() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Package core repeat.go
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"speedgo/core/stats"
+)
+
+// RepeatSummary aggregates the speeds of the runs of a --repeat test
+type RepeatSummary struct {
+	Runs   int
+	Mean   float64 // Mbps
+	Min    float64
+	Max    float64
+	StdDev float64
+}
+
+func summarizeRuns(speeds []float64) RepeatSummary {
+	return RepeatSummary{
+		Runs:   len(speeds),
+		Mean:   stats.Mean(speeds),
+		Min:    stats.Min(speeds),
+		Max:    stats.Max(speeds),
+		StdDev: stats.StdDev(speeds),
+	}
+}
+
+// parseRepeat validates --repeat and --interval. Prometheus output holds
+// one sample per metric, so it cannot take several runs.
+func parseRepeat(repeat int, interval time.Duration, format string) error {
+	if repeat < 1 {
+		return fmt.Errorf("invalid --repeat %d (expected at least 1 run)", repeat)
+	}
+	if interval < 0 {
+		return errors.New("--interval cannot be negative")
+	}
+	if repeat > 1 && format == "prometheus" {
+		return errors.New("--repeat cannot be combined with --format=prometheus")
+	}
+	return nil
+}
+
+// repeatEncoders returns the encoders for the first and the later runs of
+// a --repeat test, which share one output. JSON is written as JSON Lines,
+// one compact document per run, and CSV writes its header row only once.
+func repeatEncoders(encoder Encoder) (first, rest Encoder) {
+	switch encoder.(type) {
+	case jsonEncoder:
+		return jsonEncoder{lines: true}, jsonEncoder{lines: true}
+	case csvEncoder:
+		return csvEncoder{}, csvEncoder{noHeader: true}
+	}
+	return encoder, encoder
+}
+
+// waitForRun sleeps until interval has passed since the previous run
+// started. It returns ctx.Err() if the test is interrupted meanwhile.
+func waitForRun(ctx context.Context, banner io.Writer, run, total int, interval, previous time.Duration) error {
+	wait := max(interval-previous, 0)
+	fmt.Fprintf(banner, "\nRun %d of %d in %v...\n", run, total, wait.Round(time.Second))
+	return sleepCtx(ctx, wait)
+}
+
+// summaryWriter returns where the repeat summary goes: after the results
+// for the human-readable formats, and to stderr for the others so their
+// output stays machine-readable
+func summaryWriter(w io.Writer, format, template string) io.Writer {
+	switch {
+	case template != "":
+		return os.Stderr
+	case format == "json", format == "csv", format == "prometheus":
+		return os.Stderr
+	}
+	return w
+}
+
+func printRepeatSummary(w io.Writer, direction string, summary RepeatSummary, unit string) {
+	fmt.Fprintf(w, "\n\n%s SUMMARY (%d runs)\n", strings.ToUpper(direction), summary.Runs)
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+	fmt.Fprintf(w, "Average: %s\n", formatSpeed(summary.Mean, unit))
+	fmt.Fprintf(w, "Minimum: %s\n", formatSpeed(summary.Min, unit))
+	fmt.Fprintf(w, "Maximum: %s\n", formatSpeed(summary.Max, unit))
+	fmt.Fprintf(w, "Std dev: %s\n", formatSpeed(summary.StdDev, unit))
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+}
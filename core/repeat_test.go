@@ -0,0 +1,100 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeRuns(t *testing.T) {
+	tests := []struct {
+		name   string
+		speeds []float64
+		want   RepeatSummary
+	}{
+		{"no runs", nil, RepeatSummary{}},
+		{"one run", []float64{42}, RepeatSummary{Runs: 1, Mean: 42, Min: 42, Max: 42}},
+		{"several runs", []float64{90, 110, 100, 100}, RepeatSummary{Runs: 4, Mean: 100, Min: 90, Max: 110, StdDev: 7.0710678118654755}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeRuns(tt.speeds); got != tt.want {
+				t.Errorf("summarizeRuns(%v) = %+v, want %+v", tt.speeds, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunDownloadRepeatedOutput checks that the runs of --repeat share
+// one output that stays readable in each format
+func TestRunDownloadRepeatedOutput(t *testing.T) {
+	server := newTestFileServer(t, 1024, 0, 0)
+
+	tests := []struct {
+		format string
+		check  func(t *testing.T, out string)
+	}{
+		{"csv", func(t *testing.T, out string) {
+			lines := strings.Split(strings.TrimSpace(out), "\n")
+			if len(lines) != 4 || lines[0] != "bytes,duration_s,speed_mbps,error" {
+				t.Errorf("want one header row and 3 rows, got:\n%s", out)
+			}
+			for _, line := range lines[1:] {
+				if !strings.HasPrefix(line, "1024,") {
+					t.Errorf("row %q, want 1024 bytes", line)
+				}
+			}
+		}},
+		{"json", func(t *testing.T, out string) {
+			scanner := bufio.NewScanner(strings.NewReader(out))
+			runs := 0
+			for scanner.Scan() {
+				var run downloadJSON
+				if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+					t.Fatalf("line %q is not a JSON document: %v", scanner.Text(), err)
+				}
+				if run.Bytes != 1024 {
+					t.Errorf("run bytes = %d, want 1024", run.Bytes)
+				}
+				runs++
+			}
+			if runs != 3 {
+				t.Errorf("got %d JSON lines, want 3:\n%s", runs, out)
+			}
+		}},
+		{"table", func(t *testing.T, out string) {
+			if n := strings.Count(out, "DOWNLOAD SUMMARY (3 runs)"); n != 1 {
+				t.Errorf("summary printed %d times, want once:\n%s", n, out)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results")
+			config := &DownloadConfig{
+				URLs:        []string{server.URL},
+				Concurrency: 1,
+				Unit:        "mbps",
+				Format:      tt.format,
+				Out:         path,
+				Repeat:      3,
+			}
+			encoder, err := newEncoder(tt.format, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := runDownloadRepeated(context.Background(), config, encoder); err != nil {
+				t.Fatalf("runDownloadRepeated: %v", err)
+			}
+			out, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, string(out))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Package core sampler.go
+package core
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sample is the number of bytes transferred during one sampling interval
+type Sample struct {
+	Offset time.Duration // End of the interval, relative to the sampler start
+	Bytes  int64
+	Paused bool // The test was paused for some of the interval
+}
+
+// sampler periodically reads a shared byte counter and records the delta
+// for each interval until its context is done
+type sampler struct {
+	clock    Clock
+	counter  *int64
+	interval time.Duration
+	pause    *pauseController // May be nil
+	done     chan struct{}
+
+	mu         sync.Mutex
+	start      time.Time
+	last       int64
+	lastPaused time.Duration
+	samples    []Sample
+}
+
+func startSampler(ctx context.Context, clock Clock, counter *int64, interval time.Duration, pause *pauseController) *sampler {
+	s := newSampler(clock, counter, interval, pause)
+	go s.run(ctx)
+	return s
+}
+
+func newSampler(clock Clock, counter *int64, interval time.Duration, pause *pauseController) *sampler {
+	s := &sampler{
+		clock:    clock,
+		counter:  counter,
+		interval: interval,
+		pause:    pause,
+		done:     make(chan struct{}),
+	}
+	s.reset()
+	return s
+}
+
+func (s *sampler) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.interval):
+			s.record()
+		}
+	}
+}
+
+// record appends the bytes counted since the previous sample
+func (s *sampler) record() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := atomic.LoadInt64(s.counter)
+	// The paused total grows during a pause, so any change means the
+	// interval overlapped one
+	paused := s.pause.pausedFor()
+	s.samples = append(s.samples, Sample{
+		Offset: s.clock.Since(s.start),
+		Bytes:  current - s.last,
+		Paused: paused != s.lastPaused,
+	})
+	s.last, s.lastPaused = current, paused
+}
+
+// reset drops the samples so far and counts from the counter's current
+// value, when the warmup ends
+func (s *sampler) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.start = s.clock.Now()
+	s.last = atomic.LoadInt64(s.counter)
+	s.lastPaused = s.pause.pausedFor()
+	s.samples = nil
+}
+
+// Samples waits for the sampler to stop and returns the recorded samples
+func (s *sampler) Samples() []Sample {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.samples
+}
+
+// peakRate returns the highest per-interval throughput across the samples
+// in Mbps
+func peakRate(samples []Sample, interval time.Duration) float64 {
+	var peak int64
+	for _, sample := range samples {
+		peak = max(peak, sample.Bytes)
+	}
+	return float64(peak*8) / (1000 * 1000 * interval.Seconds())
+}
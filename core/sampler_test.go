@@ -0,0 +1,44 @@
+package core
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSamplerResetDropsWarmup(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	clock := newFakeClock(time.Unix(0, 0))
+	var counter int64
+	s := newSampler(clock, &counter, interval, nil)
+
+	// A fast warmup interval that must not count toward the peak
+	atomic.AddInt64(&counter, 50_000)
+	clock.Advance(interval)
+	s.record()
+
+	s.reset()
+	for _, n := range []int64{10_000, 20_000} {
+		atomic.AddInt64(&counter, n)
+		clock.Advance(interval)
+		s.record()
+	}
+	close(s.done)
+
+	want := []Sample{
+		{Offset: interval, Bytes: 10_000},
+		{Offset: 2 * interval, Bytes: 20_000},
+	}
+	got := s.Samples()
+	if len(got) != len(want) {
+		t.Fatalf("got %d samples %+v, want %+v", len(got), got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sample %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if peak, want := peakRate(got, interval), 1.6; peak != want {
+		t.Errorf("peakRate = %v Mbps, want %v", peak, want)
+	}
+}
@@ -0,0 +1,367 @@
+// Package core servers.go
+package core
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"speedgo/commands"
+)
+
+const (
+	// serverListTimeout bounds fetching the server list
+	serverListTimeout = 15 * time.Second
+
+	// serverPingInterval spaces the pings to one server while ranking
+	serverPingInterval = 100 * time.Millisecond
+
+	// serverDownloadChunks is the ckSize passed to a server's download
+	// endpoint, in MB
+	serverDownloadChunks = 100
+
+	// autoServerPings and autoServerTimeout bound the HEAD requests
+	// --auto-server times before the download
+	autoServerPings   = 3
+	autoServerTimeout = 2 * time.Second
+)
+
+// ErrUnknownServer is returned when --server names an ID missing from the
+// server list
+var ErrUnknownServer = errors.New("server not in list")
+
+// Server is one test server from a server list
+type Server struct {
+	ID          int
+	Name        string
+	Host        string // host:port pinged to rank the server
+	URL         string // Base URL of the server's endpoints
+	DownloadURL string
+	UploadURL   string
+}
+
+// RankedServer is a server with the latency measured to it
+type RankedServer struct {
+	Server
+	Ping PingResult
+}
+
+// ServersConfig holds the settings of the servers command
+type ServersConfig struct {
+	ListURL     string
+	Count       int
+	Timeout     time.Duration
+	Concurrency int
+	Limit       int // Closest servers shown, 0 for all
+}
+
+// librespeedServer is an entry of a LibreSpeed server list. Endpoint paths
+// are relative to Server, which may omit the scheme ("//host/path/").
+type librespeedServer struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Server string `json:"server"`
+	DlURL  string `json:"dlURL"`
+	UlURL  string `json:"ulURL"`
+}
+
+func parseServersConfig(args []string) (*ServersConfig, error) {
+	cmd := commands.ServersCmd
+	if err := cmd.Parse(args); err != nil {
+		return nil, fmt.Errorf("parsing servers arguments: %w", err)
+	}
+	if err := applyFlagSources(cmd); err != nil {
+		return nil, err
+	}
+
+	config := &ServersConfig{
+		ListURL:     cmd.Lookup("list-url").Value.String(),
+		Count:       cmd.Lookup("count").Value.(flag.Getter).Get().(int),
+		Timeout:     cmd.Lookup("timeout").Value.(flag.Getter).Get().(time.Duration),
+		Concurrency: cmd.Lookup("concurrency").Value.(flag.Getter).Get().(int),
+		Limit:       cmd.Lookup("limit").Value.(flag.Getter).Get().(int),
+	}
+	if config.ListURL == "" {
+		config.ListURL = DefaultServerListURL
+	}
+	if config.Count < 1 {
+		return nil, fmt.Errorf("invalid --count %d (expected at least 1 ping)", config.Count)
+	}
+	if config.Concurrency < 1 {
+		return nil, fmt.Errorf("invalid --concurrency %d (expected at least 1 server at a time)", config.Concurrency)
+	}
+	if config.Limit < 0 {
+		return nil, errors.New("--limit cannot be negative")
+	}
+	return config, nil
+}
+
+// RunServers fetches the server list, pings every server and prints them
+// from closest to farthest
+func RunServers(ctx context.Context, args []string) error {
+	config, err := parseServersConfig(args)
+	if err != nil {
+		return err
+	}
+
+	client := newSpeedClient(speedClientConfig{RequestTimeout: serverListTimeout})
+	servers, err := fetchServers(ctx, client, config.ListURL)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Pinging %d servers from %s...\n", len(servers), config.ListURL)
+	ranked := rankServers(ctx, servers, config)
+	if config.Limit > 0 && len(ranked) > config.Limit {
+		ranked = ranked[:config.Limit]
+	}
+	printServers(ranked)
+	return nil
+}
+
+// fetchServers downloads and parses a LibreSpeed-format server list
+func fetchServers(ctx context.Context, client *http.Client, listURL string) ([]Server, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating server list request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching server list: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching server list: status %s", resp.Status)
+	}
+
+	var entries []librespeedServer
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("parsing server list: %w", err)
+	}
+
+	servers := make([]Server, 0, len(entries))
+	for _, entry := range entries {
+		server, err := entry.server()
+		if err != nil {
+			// One malformed entry should not hide the rest of the list
+			continue
+		}
+		servers = append(servers, server)
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("server list %s has no usable servers", listURL)
+	}
+	return servers, nil
+}
+
+// server resolves the entry's endpoints against its base URL
+func (e librespeedServer) server() (Server, error) {
+	base := e.Server
+	if strings.HasPrefix(base, "//") {
+		base = "https:" + base
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+		return Server{}, fmt.Errorf("invalid server URL %q", e.Server)
+	}
+	dl, err := baseURL.Parse(e.DlURL)
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid download URL %q: %w", e.DlURL, err)
+	}
+	ul, err := baseURL.Parse(e.UlURL)
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid upload URL %q: %w", e.UlURL, err)
+	}
+
+	query := dl.Query()
+	query.Set("ckSize", strconv.Itoa(serverDownloadChunks))
+	dl.RawQuery = query.Encode()
+
+	return Server{
+		ID:          e.ID,
+		Name:        e.Name,
+		Host:        hostPort(baseURL),
+		URL:         baseURL.String(),
+		DownloadURL: dl.String(),
+		UploadURL:   ul.String(),
+	}, nil
+}
+
+// hostPort returns the host:port a URL connects to
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// findServer looks up the server with the given ID in a server list,
+// fetched with the TLS and proxy settings of the test that will use it
+func findServer(ctx context.Context, clientConfig speedClientConfig, listURL string, id int) (Server, error) {
+	clientConfig.RequestTimeout = serverListTimeout
+	servers, err := fetchServers(ctx, newSpeedClient(clientConfig), listURL)
+	if err != nil {
+		return Server{}, err
+	}
+	for _, server := range servers {
+		if server.ID == id {
+			return server, nil
+		}
+	}
+	return Server{}, fmt.Errorf("%w: no server with ID %d in %s", ErrUnknownServer, id, listURL)
+}
+
+// rankServers TCP-pings every server and sorts them by average RTT,
+// unreachable servers last
+func rankServers(ctx context.Context, servers []Server, config *ServersConfig) []RankedServer {
+	ranked := make([]RankedServer, len(servers))
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, config.Concurrency)
+
+	for i, server := range servers {
+		wg.Add(1)
+		go func(idx int, server Server) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			host, portText, _ := net.SplitHostPort(server.Host)
+			port, _ := strconv.Atoi(portText)
+			pingConfig := &PingConfig{
+				Targets:  []string{host},
+				Count:    config.Count,
+				Timeout:  config.Timeout,
+				Interval: serverPingInterval,
+				Mode:     pingModeTCP,
+				Port:     port,
+			}
+			ranked[idx] = RankedServer{
+				Server: server,
+				Ping:   pingTarget(ctx, pingProbe{target: host, mode: pingModeTCP}, pingConfig),
+			}
+		}(i, server)
+	}
+	wg.Wait()
+
+	sortRanked(ranked)
+	return ranked
+}
+
+// sortRanked orders servers by average RTT, unreachable servers last
+func sortRanked(ranked []RankedServer) {
+	slices.SortStableFunc(ranked, func(a, b RankedServer) int {
+		if (a.Ping.Received == 0) != (b.Ping.Received == 0) {
+			if a.Ping.Received == 0 {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(a.Ping.AvgRTT, b.Ping.AvgRTT)
+	})
+}
+
+// rankURLs times HEAD requests to each URL through client and returns them
+// as servers, closest first, for --auto-server. Going through the client
+// of the test keeps its proxy and TLS settings, which a TCP ping would not.
+func rankURLs(ctx context.Context, client *http.Client, urls []string) ([]RankedServer, error) {
+	ranked := make([]RankedServer, len(urls))
+	for i, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing test file URL: %w", err)
+		}
+		ranked[i].Server = Server{ID: i + 1, Name: u.Hostname(), Host: hostPort(u), URL: raw}
+	}
+
+	var wg sync.WaitGroup
+	for i := range ranked {
+		wg.Add(1)
+		go func(server *RankedServer) {
+			defer wg.Done()
+			server.Ping = pingURL(ctx, client, server.URL, server.Name)
+		}(&ranked[i])
+	}
+	wg.Wait()
+
+	sortRanked(ranked)
+	return ranked, nil
+}
+
+// pingURL sends autoServerPings HEAD requests to rawURL one after the other
+// and records the time to each response. The first includes setting up the
+// connection, as the transfers that follow will have to as well.
+func pingURL(ctx context.Context, client *http.Client, rawURL, name string) PingResult {
+	result := PingResult{Target: name}
+	for i := 0; i < autoServerPings && ctx.Err() == nil; i++ {
+		result.Sent++
+		rtt, err := headRTT(ctx, client, rawURL)
+		if err != nil {
+			result.Lost++
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		result.record(rtt)
+	}
+	result.calculateStats()
+	return result
+}
+
+// headRTT returns the time until the response to a HEAD request for rawURL.
+// Any status counts, since only the server's latency matters.
+func headRTT(ctx context.Context, client *http.Client, rawURL string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, autoServerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	resp.Body.Close()
+	return rtt, nil
+}
+
+func printServers(ranked []RankedServer) {
+	fmt.Println("\nTEST SERVERS")
+	fmt.Println(strings.Repeat("=", 80))
+	fmt.Printf("%6s  %-36s %-22s %8s %6s\n", "ID", "NAME", "HOST", "LATENCY", "LOSS")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, server := range ranked {
+		latency := "N/A"
+		if server.Ping.Received > 0 {
+			latency = fmt.Sprintf("%.1fms", float64(server.Ping.AvgRTT.Microseconds())/1000)
+		}
+		fmt.Printf("%6d  %-36s %-22s %8s %5.0f%%\n",
+			server.ID, truncate(server.Name, 36), truncate(server.Host, 22), latency, server.Ping.lossPercent())
+	}
+	fmt.Println(strings.Repeat("=", 80))
+	fmt.Println("Use --server=<ID> with download or upload to test against one of them")
+}
+
+// truncate shortens s to at most n runes so it fits a table column
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "~"
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serverList is a LibreSpeed-format list with one entry of each URL form
+const serverList = `[
+	{"id": 1, "name": "Frankfurt", "server": "https://fra.example.com/", "dlURL": "garbage.php", "ulURL": "empty.php"},
+	{"id": 7, "name": "Tokyo", "server": "//tyo.example.com:8080/speed/", "dlURL": "garbage.php", "ulURL": "empty.php"},
+	{"id": 9, "name": "Broken", "server": "ftp://bad.example.com/", "dlURL": "garbage.php", "ulURL": "empty.php"}
+]`
+
+func TestFindServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		id      int
+		want    Server
+		wantErr string
+	}{
+		{"found", serverList, http.StatusOK, 7, Server{
+			ID:          7,
+			Name:        "Tokyo",
+			Host:        "tyo.example.com:8080",
+			URL:         "https://tyo.example.com:8080/speed/",
+			DownloadURL: "https://tyo.example.com:8080/speed/garbage.php?ckSize=100",
+			UploadURL:   "https://tyo.example.com:8080/speed/empty.php",
+		}, ""},
+		{"unknown ID", serverList, http.StatusOK, 42, Server{}, "no server with ID 42"},
+		{"malformed entry skipped", serverList, http.StatusOK, 9, Server{}, "no server with ID 9"},
+		{"malformed body", `{"id": 1`, http.StatusOK, 1, Server{}, "parsing server list"},
+		{"no usable servers", `[]`, http.StatusOK, 1, Server{}, "has no usable servers"},
+		{"error status", serverList, http.StatusNotFound, 1, Server{}, "status 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer list.Close()
+
+			got, err := findServer(context.Background(), speedClientConfig{}, list.URL, tt.id)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("findServer(%d) error = %v, want one containing %q", tt.id, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findServer(%d): %v", tt.id, err)
+			}
+			if got != tt.want {
+				t.Errorf("findServer(%d) = %+v, want %+v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindServerUnknownIsErrUnknownServer(t *testing.T) {
+	list := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(serverList))
+	}))
+	defer list.Close()
+
+	_, err := findServer(context.Background(), speedClientConfig{}, list.URL, 42)
+	if !errors.Is(err, ErrUnknownServer) {
+		t.Errorf("error = %v, want %v", err, ErrUnknownServer)
+	}
+}
+
+func TestSelectFastestURL(t *testing.T) {
+	newHost := func(delay time.Duration) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	fast, slow := newHost(0), newHost(150*time.Millisecond)
+	down := newHost(0)
+	down.Close()
+
+	tests := []struct {
+		name string
+		urls []string
+		want []string
+	}{
+		{"fast first", []string{fast.URL + "/file", slow.URL + "/file"}, []string{fast.URL + "/file"}},
+		{"fast last", []string{slow.URL + "/file", fast.URL + "/file"}, []string{fast.URL + "/file"}},
+		{"unreachable host skipped", []string{down.URL + "/file", slow.URL + "/file"}, []string{slow.URL + "/file"}},
+		{"no host answers", []string{down.URL + "/a", down.URL + "/b"}, []string{down.URL + "/a", down.URL + "/b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &DownloadConfig{URLs: tt.urls, Format: "json"}
+			if err := selectFastestURL(context.Background(), config); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(config.URLs, tt.want) {
+				t.Errorf("URLs = %q, want %q", config.URLs, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectFastestURLUsesProxy(t *testing.T) {
+	var proxied atomic.Int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	// The host does not exist, so only a request through the proxy answers
+	config := &DownloadConfig{URLs: []string{"http://speedgo.invalid/file"}, Format: "json", Proxy: proxyURL}
+	if err := selectFastestURL(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	if proxied.Load() != autoServerPings {
+		t.Errorf("proxy saw %d requests, want all %d", proxied.Load(), autoServerPings)
+	}
+}
@@ -0,0 +1,21 @@
+// Package core sleep.go
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// sleepCtx pauses for d or until ctx is done, whichever comes first, and
+// returns ctx.Err() if the sleep was cut short
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSleepCtx(t *testing.T) {
+	tests := []struct {
+		name    string
+		sleep   time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{"sleeps through", 10 * time.Millisecond, func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		}, nil},
+		{"cancelled", time.Minute, func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled},
+		{"deadline", time.Minute, func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 20*time.Millisecond)
+		}, context.DeadlineExceeded},
+		{"already done", time.Minute, func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx, cancel
+		}, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			start := time.Now()
+			err := sleepCtx(ctx, tt.sleep)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("sleepCtx() = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); tt.wantErr != nil && elapsed > time.Second {
+				t.Errorf("returned after %v, want promptly once the context was done", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+// Package core speedtest.go
+package core
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"speedgo/commands"
+)
+
+// latencyTestInterval spaces the pings of the combined test; the full
+// second of the ping command would make a 10-ping test take 10s
+const latencyTestInterval = 200 * time.Millisecond
+
+// SpeedTestReport holds the results of the combined test
+type SpeedTestReport struct {
+	Ping     PingResult
+	Download DownloadStats
+	Upload   UploadStats
+
+	// Latency is set with --bidirectional: Ping is the idle latency and
+	// Loaded was measured while both transfers ran
+	Latency *LoadedLatency
+
+	Cancelled bool // Interrupted; phases that had not started are left empty
+}
+
+// speedTestConfig holds the configurations of the three phases of the
+// combined test, derived from its shared flags
+type speedTestConfig struct {
+	ping     *PingConfig
+	download *DownloadConfig
+	upload   *UploadConfig
+	verbose  bool
+	format   string // table or prometheus
+	out      string // Results destination, as for --out of the other commands
+	log      string // JSON Lines file the results are appended to, or ""
+
+	bidirectional bool // Run download and upload at the same time
+}
+
+func parseSpeedTestConfig(args []string) (*speedTestConfig, error) {
+	cmd := commands.TestCmd
+	if err := cmd.Parse(args); err != nil {
+		return nil, fmt.Errorf("parsing test arguments: %w", err)
+	}
+	if err := applyFlagSources(cmd); err != nil {
+		return nil, err
+	}
+
+	target := cmd.Lookup("ping-target").Value.String()
+	if target == "" {
+		target = DefaultPingTargets[0]
+	}
+	count := cmd.Lookup("count").Value.(flag.Getter).Get().(int)
+	if count < 1 {
+		return nil, errors.New("--count must be at least 1")
+	}
+	duration := cmd.Lookup("duration").Value.(flag.Getter).Get().(time.Duration)
+	if duration < time.Second {
+		return nil, errors.New("--duration must be at least 1s")
+	}
+	concurrency := cmd.Lookup("concurrency").Value.String()
+	unit := cmd.Lookup("unit").Value.String()
+	verbose := cmd.Lookup("verbose").Value.String()
+	format := cmd.Lookup("format").Value.String()
+	if format != "table" && format != "prometheus" {
+		return nil, fmt.Errorf("unknown output format %q (expected table or prometheus)", format)
+	}
+
+	// The transfer phases get the defaults and validation of their own
+	// commands from fresh flag sets, so the download and upload commands'
+	// environment variables and config files do not leak into this one
+	phaseArgs := []string{
+		"--concurrency=" + concurrency,
+		"--unit=" + unit,
+		"--verbose=" + verbose,
+	}
+	downloadCmd := commands.NewDownloadCmd()
+	if err := downloadCmd.Parse(phaseArgs); err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	download, err := downloadConfigFromFlags(downloadCmd)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	uploadCmd := commands.NewUploadCmd()
+	if err := uploadCmd.Parse(phaseArgs); err != nil {
+		return nil, fmt.Errorf("upload: %w", err)
+	}
+	upload, err := uploadConfigFromFlags(uploadCmd)
+	if err != nil {
+		return nil, fmt.Errorf("upload: %w", err)
+	}
+	// Both phases run for exactly --duration; the upload flag only takes
+	// whole seconds
+	download.Duration, upload.Duration = duration, duration
+
+	return &speedTestConfig{
+		ping: &PingConfig{
+			Targets:     []string{target},
+			Count:       count,
+			Timeout:     time.Second,
+			Interval:    latencyTestInterval,
+			Concurrency: 1,
+			InFlight:    1,
+			Size:        defaultPayloadSize,
+			Mode:        pingModeICMP,
+			Verbose:     download.Verbose,
+		},
+		download: download,
+		upload:   upload,
+		verbose:  download.Verbose,
+		format:   format,
+		out:      cmd.Lookup("out").Value.String(),
+		log:      cmd.Lookup("log").Value.String(),
+
+		bidirectional: cmd.Lookup("bidirectional").Value.(flag.Getter).Get().(bool),
+	}, nil
+}
+
+// RunSpeedTest measures latency, download and upload one after the other
+// and prints a combined summary
+func RunSpeedTest(ctx context.Context, args []string) error {
+	config, err := parseSpeedTestConfig(args)
+	if err != nil {
+		return err
+	}
+
+	report := measureSpeedTest(ctx, config)
+	if err := logResults(config.log, speedTestRecords(time.Now(), report)...); err != nil {
+		return err
+	}
+	return writeResults(config.out, func(w io.Writer) error {
+		if config.format == "prometheus" {
+			writeSpeedTestMetrics(w, report)
+			return nil
+		}
+		if config.verbose {
+			printResults(w, []PingResult{report.Ping}, false)
+			printDownloadResults(w, config.download, report.Download)
+			printUploadResults(w, config.upload, report.Upload)
+		}
+		printSpeedTestReport(w, config, report)
+		return nil
+	})
+}
+
+// speedTestRecords returns the history records of the phases that ran
+func speedTestRecords(now time.Time, report SpeedTestReport) []HistoryRecord {
+	records := pingRecords(now, []PingResult{report.Ping})
+	if report.Download.Duration > 0 {
+		records = append(records, downloadRecord(now, report.Download))
+	}
+	if report.Upload.Duration > 0 {
+		records = append(records, uploadRecord(now, report.Upload))
+	}
+	return records
+}
+
+func measureSpeedTest(ctx context.Context, config *speedTestConfig) SpeedTestReport {
+	var report SpeedTestReport
+
+	banner := bannerWriter(config.format)
+	fmt.Fprintf(banner, "Measuring latency to %s...\n", config.ping.Targets[0])
+	report.Ping = pingTargets(ctx, config.ping)[0]
+	if ctx.Err() != nil {
+		report.Cancelled = true
+		return report
+	}
+
+	if config.bidirectional {
+		measureBidirectional(ctx, config, &report)
+		report.Cancelled = ctx.Err() != nil
+		return report
+	}
+
+	fmt.Fprintf(banner, "Measuring download speed (%v, %d streams)...\n",
+		config.download.Duration, config.download.Concurrency)
+	report.Download = measureDownloadSpeed(ctx, config.download)
+	if ctx.Err() != nil {
+		report.Cancelled = true
+		return report
+	}
+
+	fmt.Fprintf(banner, "Measuring upload speed (%v, %d streams)...\n",
+		config.upload.Duration, config.upload.Concurrency)
+	report.Upload = measureUploadSpeed(ctx, config.upload)
+	report.Cancelled = report.Upload.Cancelled
+	return report
+}
+
+// measureBidirectional runs the download and upload phases at the same time
+// and pings the latency target while both saturate the link
+func measureBidirectional(ctx context.Context, config *speedTestConfig, report *SpeedTestReport) {
+	fmt.Fprintf(bannerWriter(config.format), "Measuring download and upload at once (%v, %d streams each)...\n",
+		config.download.Duration, config.download.Concurrency)
+
+	// The two progress lines would overwrite each other, so neither is shown
+	download, upload := *config.download, *config.upload
+	download.Verbose, upload.Verbose = false, false
+
+	// Both transfers and the loaded pings share one context, so an
+	// interrupt stops them together and the pings end with the transfers
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	target := config.ping.Targets[0]
+	loaded := startLoadedLatency(ctx, target)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		report.Download = measureDownloadSpeed(ctx, &download)
+	}()
+	go func() {
+		defer wg.Done()
+		report.Upload = measureUploadSpeed(ctx, &upload)
+	}()
+	wg.Wait()
+
+	cancel()
+	report.Latency = &LoadedLatency{Target: target, Idle: report.Ping, Loaded: <-loaded}
+}
+
+func printSpeedTestReport(w io.Writer, config *speedTestConfig, report SpeedTestReport) {
+	fmt.Fprintf(w, "\n\nSPEED TEST SUMMARY\n")
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+
+	ping := report.Ping
+	if ping.Received == 0 {
+		fmt.Fprintf(w, "Latency (%s): no replies\n", ping.Target)
+	} else {
+		fmt.Fprintf(w, "Latency (%s): %.1fms\n", ping.Target, float64(ping.AvgRTT.Microseconds())/1000)
+		fmt.Fprintf(w, "Jitter: %.1fms\n", float64(ping.Jitter.Microseconds())/1000)
+		fmt.Fprintf(w, "Packet loss: %.1f%%\n", ping.lossPercent())
+	}
+	download := speedText(report.Download.Speed, report.Download.Duration, config.download.Unit)
+	upload := speedText(report.Upload.Speed, report.Upload.Duration, config.upload.Unit)
+	if report.Cancelled {
+		// Phases that never started have no duration at all
+		if report.Download.Duration == 0 {
+			download = "skipped"
+		}
+		if report.Upload.Duration == 0 {
+			upload = "skipped"
+		}
+	}
+	fmt.Fprintf(w, "Download: %s\n", download)
+	fmt.Fprintf(w, "Upload: %s\n", upload)
+	if report.Latency != nil {
+		fmt.Fprintln(w, "Download and upload ran at the same time")
+		printLoadedLatency(w, report.Latency)
+	}
+
+	for _, phase := range []struct {
+		name string
+		err  error
+	}{{"Download", report.Download.Error}, {"Upload", report.Upload.Error}} {
+		// Requests cut off by the end of the phase are not worth reporting
+		if phase.err != nil && !errors.Is(phase.err, context.DeadlineExceeded) {
+			fmt.Fprintf(w, "%s errors: %v\n", phase.name, phase.err)
+		}
+	}
+	if report.Cancelled {
+		fmt.Fprintln(w, "Interrupted, results are partial")
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"speedgo/commands"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintSpeedTestReportInterrupted(t *testing.T) {
+	config := &speedTestConfig{download: &DownloadConfig{Unit: "mbps"}, upload: &UploadConfig{Unit: "mbps"}}
+	download := DownloadStats{BytesReceived: 12_500_000, Duration: 10 * time.Second, Speed: 10}
+
+	tests := []struct {
+		name   string
+		report SpeedTestReport
+		want   []string
+		absent []string
+	}{
+		{
+			name:   "complete",
+			report: SpeedTestReport{Download: download, Upload: UploadStats{Duration: 10 * time.Second, Speed: 5}},
+			want:   []string{"Download: 10.00 Mbps", "Upload: 5.00 Mbps"},
+			absent: []string{"skipped", "Interrupted"},
+		},
+		{
+			name:   "interrupted during ping",
+			report: SpeedTestReport{Cancelled: true},
+			want:   []string{"Download: skipped", "Upload: skipped", "Interrupted, results are partial"},
+		},
+		{
+			name:   "interrupted after download",
+			report: SpeedTestReport{Download: download, Cancelled: true},
+			want:   []string{"Download: 10.00 Mbps", "Upload: skipped", "Interrupted, results are partial"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printSpeedTestReport(&buf, config, tt.report)
+			out := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("report does not contain %q:\n%s", want, out)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(out, absent) {
+					t.Errorf("report contains %q:\n%s", absent, out)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSpeedTestConfigPhases(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		env             map[string]string
+		wantDuration    time.Duration
+		wantConcurrency int
+	}{
+		{"defaults", nil, nil, 10 * time.Second, 4},
+		{
+			name: "download and upload settings stay out",
+			env: map[string]string{
+				"SPEEDGO_DOWNLOAD_CONCURRENCY": "9",
+				"SPEEDGO_DOWNLOAD_DURATION":    "1m",
+				"SPEEDGO_UPLOAD_CONCURRENCY":   "7",
+				"SPEEDGO_UPLOAD_DURATION":      "45",
+				"SPEEDGO_UPLOAD_URL":           "ftp://invalid",
+			},
+			wantDuration:    10 * time.Second,
+			wantConcurrency: 4,
+		},
+		{
+			name:            "test settings apply",
+			env:             map[string]string{"SPEEDGO_TEST_CONCURRENCY": "6", "SPEEDGO_TEST_DURATION": "5s"},
+			wantDuration:    5 * time.Second,
+			wantConcurrency: 6,
+		},
+		// After the environment cases: see resetFlags
+		{"fractional duration", []string{"--duration=2500ms"}, nil, 2500 * time.Millisecond, 4},
+		{"own flags", []string{"--duration=3s", "--concurrency=2"}, nil, 3 * time.Second, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(t, commands.TestCmd)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			config, err := parseSpeedTestConfig(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, phase := range []struct {
+				name        string
+				duration    time.Duration
+				concurrency int
+			}{
+				{"download", config.download.Duration, config.download.Concurrency},
+				{"upload", config.upload.Duration, config.upload.Concurrency},
+			} {
+				if phase.duration != tt.wantDuration || phase.concurrency != tt.wantConcurrency {
+					t.Errorf("%s: duration %v with %d streams, want %v with %d",
+						phase.name, phase.duration, phase.concurrency, tt.wantDuration, tt.wantConcurrency)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package stats
+
+import "time"
+
+// RTOEstimator derives a retransmission-style timeout from observed RTTs
+// using the TCP algorithm of RFC 6298:
+//
+//	first sample:  SRTT = R, RTTVAR = R/2
+//	later samples: RTTVAR = 3/4 RTTVAR + 1/4 |SRTT - R|
+//	               SRTT   = 7/8 SRTT + 1/8 R
+//	timeout:       SRTT + 4 RTTVAR
+//
+// Until the first sample the timeout is the initial value. Each timeout
+// doubles the current value (Backoff) until the next sample arrives. The
+// result is always clamped to [min, max].
+type RTOEstimator struct {
+	srtt, rttvar time.Duration
+	rto          time.Duration
+	min, max     time.Duration
+	sampled      bool
+}
+
+// NewRTOEstimator returns an estimator starting at initial and bounded by
+// min and max
+func NewRTOEstimator(initial, min, max time.Duration) *RTOEstimator {
+	e := &RTOEstimator{min: min, max: max}
+	e.rto = e.clamp(initial)
+	return e
+}
+
+// Update folds one measured RTT into the estimate
+func (e *RTOEstimator) Update(rtt time.Duration) {
+	if !e.sampled {
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+		e.sampled = true
+	} else {
+		diff := e.srtt - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		e.rttvar = (3*e.rttvar + diff) / 4
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+	e.rto = e.clamp(e.srtt + 4*e.rttvar)
+}
+
+// Backoff doubles the timeout after a probe went unanswered
+func (e *RTOEstimator) Backoff() {
+	e.rto = e.clamp(2 * e.rto)
+}
+
+// Timeout returns the current timeout
+func (e *RTOEstimator) Timeout() time.Duration { return e.rto }
+
+func (e *RTOEstimator) clamp(d time.Duration) time.Duration {
+	return max(e.min, min(d, e.max))
+}
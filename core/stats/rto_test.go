@@ -0,0 +1,46 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTOEstimator(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name  string
+		steps []time.Duration // RTT samples; -1 is a timeout (Backoff)
+		want  time.Duration
+	}{
+		{"initial", nil, time.Second},
+		{"first sample", []time.Duration{100 * ms}, 300 * ms}, // 100 + 4*50
+		// RTTVAR = 3/4*50 + 1/4*0 = 37.5, SRTT = 100, so 100 + 150
+		{"steady samples", []time.Duration{100 * ms, 100 * ms}, 250 * ms},
+		// RTTVAR = 3/4*50 + 1/4*100 = 62.5, SRTT = 7/8*100 + 1/8*200 = 112.5
+		{"slower sample", []time.Duration{100 * ms, 200 * ms}, 362500 * time.Microsecond},
+		{"backoff doubles", []time.Duration{100 * ms, -1}, 600 * ms},
+		{"repeated backoff is capped", []time.Duration{100 * ms, -1, -1, -1, -1, -1}, 5 * time.Second},
+		{"sample after backoff", []time.Duration{100 * ms, -1, 100 * ms}, 250 * ms},
+		{"clamped to min", []time.Duration{ms, ms, ms, ms}, 50 * ms},
+		{"clamped to max", []time.Duration{10 * time.Second}, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewRTOEstimator(time.Second, 50*ms, 5*time.Second)
+			for _, step := range tt.steps {
+				if step < 0 {
+					e.Backoff()
+				} else {
+					e.Update(step)
+				}
+			}
+			if got := e.Timeout(); got != tt.want {
+				t.Errorf("Timeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := NewRTOEstimator(time.Minute, 50*ms, 5*time.Second).Timeout(); got != 5*time.Second {
+		t.Errorf("initial timeout above max = %v, want it clamped to 5s", got)
+	}
+}
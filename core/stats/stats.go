@@ -0,0 +1,100 @@
+// Package stats provides summary statistics over samples such as round-trip
+// times (time.Duration) or throughput readings (float64). All functions
+// return the zero value for an empty slice and never modify their input.
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// Number is the set of sample types the statistics operate on
+type Number interface {
+	~int64 | ~float64
+}
+
+// Min returns the smallest value
+func Min[T Number](values []T) T {
+	if len(values) == 0 {
+		return 0
+	}
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
+
+// Max returns the largest value
+func Max[T Number](values []T) T {
+	if len(values) == 0 {
+		return 0
+	}
+	result := values[0]
+	for _, v := range values[1:] {
+		if v > result {
+			result = v
+		}
+	}
+	return result
+}
+
+// Mean returns the arithmetic mean
+func Mean[T Number](values []T) T {
+	if len(values) == 0 {
+		return 0
+	}
+	var total float64
+	for _, v := range values {
+		total += float64(v)
+	}
+	return T(total / float64(len(values)))
+}
+
+// StdDev returns the population standard deviation
+func StdDev[T Number](values []T) T {
+	if len(values) == 0 {
+		return 0
+	}
+	mean := float64(Mean(values))
+	var sumSquares float64
+	for _, v := range values {
+		d := float64(v) - mean
+		sumSquares += d * d
+	}
+	return T(math.Sqrt(sumSquares / float64(len(values))))
+}
+
+// Percentile returns the p-th percentile (0-100) using linear interpolation
+// between the closest ranks
+func Percentile[T Number](values []T, p float64) T {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]T, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	p = math.Max(0, math.Min(100, p))
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	weight := rank - float64(lower)
+	return T(float64(sorted[lower]) + weight*(float64(sorted[upper])-float64(sorted[lower])))
+}
+
+// Jitter returns the mean absolute difference between consecutive values,
+// in their original order
+func Jitter[T Number](values []T) T {
+	if len(values) < 2 {
+		return 0
+	}
+	var total float64
+	for i := 1; i < len(values); i++ {
+		total += math.Abs(float64(values[i]) - float64(values[i-1]))
+	}
+	return T(total / float64(len(values)-1))
+}
@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name                        string
+		values                      []float64
+		min, max, mean, stdDev, jit float64
+	}{
+		{"empty", nil, 0, 0, 0, 0, 0},
+		{"single", []float64{7}, 7, 7, 7, 0, 0},
+		{"known series", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 2, 9, 5, 2, 1},
+		{"negative values", []float64{-3, 3}, -3, 3, 0, 3, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []float64{Min(tt.values), Max(tt.values), Mean(tt.values), StdDev(tt.values), Jitter(tt.values)}
+			want := []float64{tt.min, tt.max, tt.mean, tt.stdDev, tt.jit}
+			if !slices.Equal(got, want) {
+				t.Errorf("min, max, mean, stddev, jitter = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{15, 20, 35, 40, 50}
+	tests := []struct {
+		p, want float64
+	}{
+		{0, 15},
+		{25, 20},
+		{40, 29}, // Between 20 and 35 at rank 1.6
+		{50, 35},
+		{90, 46}, // Between 40 and 50 at rank 3.6
+		{100, 50},
+		{-10, 15}, // Clamped
+		{250, 50},
+	}
+	for _, tt := range tests {
+		if got := Percentile(values, tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := Percentile([]float64{}, 50); got != 0 {
+		t.Errorf("Percentile of no values = %v, want 0", got)
+	}
+}
+
+func TestDurations(t *testing.T) {
+	rtts := []time.Duration{30 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond}
+	original := slices.Clone(rtts)
+
+	tests := []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		{"Min", Min(rtts), 10 * time.Millisecond},
+		{"Max", Max(rtts), 30 * time.Millisecond},
+		{"Mean", Mean(rtts), 20 * time.Millisecond},
+		{"Percentile 50", Percentile(rtts, 50), 20 * time.Millisecond},
+		{"Jitter", Jitter(rtts), 15 * time.Millisecond}, // In the original order: 20 and 10
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if !slices.Equal(rtts, original) {
+		t.Errorf("input modified: %v, was %v", rtts, original)
+	}
+}
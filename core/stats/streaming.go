@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Running accumulates count, min, max, mean and variance in constant memory
+// using Welford's online algorithm, for runs too long to keep every sample
+type Running[T Number] struct {
+	n        int64
+	mean, m2 float64
+	min, max float64
+}
+
+// Add records one sample
+func (r *Running[T]) Add(value T) {
+	x := float64(value)
+	r.n++
+	if r.n == 1 {
+		r.min, r.max = x, x
+	} else {
+		r.min = math.Min(r.min, x)
+		r.max = math.Max(r.max, x)
+	}
+
+	delta := x - r.mean
+	r.mean += delta / float64(r.n)
+	r.m2 += delta * (x - r.mean)
+}
+
+// Count returns the number of samples recorded
+func (r *Running[T]) Count() int64 { return r.n }
+
+// Min returns the smallest sample
+func (r *Running[T]) Min() T { return T(r.min) }
+
+// Max returns the largest sample
+func (r *Running[T]) Max() T { return T(r.max) }
+
+// Mean returns the arithmetic mean of the samples
+func (r *Running[T]) Mean() T { return T(r.mean) }
+
+// StdDev returns the population standard deviation of the samples
+func (r *Running[T]) StdDev() T {
+	if r.n == 0 {
+		return 0
+	}
+	return T(math.Sqrt(r.m2 / float64(r.n)))
+}
+
+// Reservoir keeps a uniform random sample of at most a fixed number of
+// values from a stream of unknown length (Vitter's Algorithm R). Percentiles
+// of the sample approximate the percentiles of the whole stream.
+type Reservoir[T Number] struct {
+	size   int
+	seen   int64
+	values []T
+}
+
+// NewReservoir returns a reservoir holding at most size values
+func NewReservoir[T Number](size int) *Reservoir[T] {
+	return &Reservoir[T]{size: size, values: make([]T, 0, size)}
+}
+
+// Add offers one value to the reservoir
+func (r *Reservoir[T]) Add(value T) {
+	r.seen++
+	if len(r.values) < r.size {
+		r.values = append(r.values, value)
+		return
+	}
+	if i := rand.Int64N(r.seen); i < int64(r.size) {
+		r.values[i] = value
+	}
+}
+
+// Values returns a copy of the current sample
+func (r *Reservoir[T]) Values() []T {
+	result := make([]T, len(r.values))
+	copy(result, r.values)
+	return result
+}
@@ -0,0 +1,79 @@
+package stats
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestRunningMatchesExact(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+	}{
+		{"single", []float64{4}},
+		{"known series", []float64{2, 4, 4, 4, 5, 5, 7, 9}},
+		{"large offset", []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r Running[float64]
+			for _, v := range tt.values {
+				r.Add(v)
+			}
+			if r.Count() != int64(len(tt.values)) || r.Min() != Min(tt.values) || r.Max() != Max(tt.values) {
+				t.Errorf("count, min, max = %d, %v, %v, want %d, %v, %v",
+					r.Count(), r.Min(), r.Max(), len(tt.values), Min(tt.values), Max(tt.values))
+			}
+			if math.Abs(r.Mean()-Mean(tt.values)) > 1e-9 || math.Abs(r.StdDev()-StdDev(tt.values)) > 1e-6 {
+				t.Errorf("mean, stddev = %v, %v, want %v, %v", r.Mean(), r.StdDev(), Mean(tt.values), StdDev(tt.values))
+			}
+		})
+	}
+
+	var empty Running[float64]
+	if empty.Count() != 0 || empty.Mean() != 0 || empty.StdDev() != 0 {
+		t.Errorf("empty Running = %+v, want all zero", empty)
+	}
+}
+
+func TestReservoir(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		values   int
+		wantSize int
+	}{
+		{"under capacity keeps everything", 10, 5, 5},
+		{"at capacity", 10, 10, 10},
+		{"over capacity samples", 10, 1000, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReservoir[int64](tt.size)
+			for v := range int64(tt.values) {
+				r.Add(v)
+			}
+			got := r.Values()
+			if len(got) != tt.wantSize {
+				t.Fatalf("kept %d values, want %d", len(got), tt.wantSize)
+			}
+			if tt.values <= tt.size {
+				for i, v := range got {
+					if v != int64(i) {
+						t.Fatalf("Values() = %v, want every value in order", got)
+					}
+				}
+			}
+			distinct := slices.Compact(slices.Sorted(slices.Values(got)))
+			if len(distinct) != len(got) || Min(got) < 0 || Max(got) >= int64(tt.values) {
+				t.Errorf("Values() = %v, want distinct values from the stream", got)
+			}
+
+			got[0] = -1
+			if r.Values()[0] == -1 {
+				t.Error("Values() returned the reservoir's own slice")
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Package core status.go
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// PingStatus is the health classification of a ping target
+type PingStatus int
+
+const (
+	StatusHealthy PingStatus = iota
+	StatusDegraded
+	StatusDown
+)
+
+// Thresholds above which a reachable target is considered degraded
+const (
+	degradedLossPercent = 0.0
+	degradedAvgRTT      = 150 * time.Millisecond
+)
+
+func (s PingStatus) String() string {
+	switch s {
+	case StatusHealthy:
+		return "healthy"
+	case StatusDegraded:
+		return "degraded"
+	default:
+		return "down"
+	}
+}
+
+// Glyph returns the single-character marker used in the results table
+func (s PingStatus) Glyph() string {
+	switch s {
+	case StatusHealthy:
+		return "✓"
+	case StatusDegraded:
+		return "!"
+	default:
+		return "✗"
+	}
+}
+
+func (s PingStatus) color() string {
+	switch s {
+	case StatusHealthy:
+		return ansiGreen
+	case StatusDegraded:
+		return ansiYellow
+	default:
+		return ansiRed
+	}
+}
+
+// classifyResult derives the health status of a target from its loss and latency
+func classifyResult(result PingResult) PingStatus {
+	if result.Received == 0 {
+		return StatusDown
+	}
+
+	lossPercent := result.lossPercent()
+	if lossPercent > degradedLossPercent || result.AvgRTT > degradedAvgRTT {
+		return StatusDegraded
+	}
+	return StatusHealthy
+}
+
+// failNever is the --fail-on=none threshold, which no status reaches
+const failNever = StatusDown + 1
+
+// ErrUnhealthyTarget is returned after the results are written when a
+// target's status reached the --fail-on threshold
+var ErrUnhealthyTarget = errors.New("unhealthy targets")
+
+// parseFailOn resolves --fail-on to the lowest status that fails the run
+func parseFailOn(s string) (PingStatus, error) {
+	switch s {
+	case "down":
+		return StatusDown, nil
+	case "degraded":
+		return StatusDegraded, nil
+	case "none":
+		return failNever, nil
+	default:
+		return 0, fmt.Errorf("invalid --fail-on %q (expected down, degraded or none)", s)
+	}
+}
+
+// checkHealth returns ErrUnhealthyTarget naming every target whose status
+// is failOn or worse. Targets an interrupt kept from being probed are left
+// out.
+func checkHealth(results []PingResult, failOn PingStatus) error {
+	var unhealthy []string
+	for _, result := range results {
+		if result.Sent == 0 {
+			continue
+		}
+		if status := classifyResult(result); status >= failOn {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s is %s", result.label(), status))
+		}
+	}
+	if len(unhealthy) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnhealthyTarget, strings.Join(unhealthy, ", "))
+}
+
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+)
+
+// parseColorMode resolves the --color flag (auto, always, never) to whether
+// output should be colorized
+func parseColorMode(mode string) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		return isTerminal(os.Stdout), nil
+	default:
+		return false, fmt.Errorf("invalid color mode %q (expected auto, always or never)", mode)
+	}
+}
+
+// statusGlyph returns the glyph for a status, colorized when enabled
+func statusGlyph(status PingStatus, color bool) string {
+	if !color {
+		return status.Glyph()
+	}
+	return status.color() + status.Glyph() + ansiReset
+}
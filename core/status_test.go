@@ -0,0 +1,68 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		result PingResult
+		want   PingStatus
+	}{
+		{"no replies", PingResult{Sent: 4, Lost: 4}, StatusDown},
+		{"fast, no loss", PingResult{Sent: 4, Received: 4, AvgRTT: 20 * time.Millisecond}, StatusHealthy},
+		{"at the RTT limit", PingResult{Sent: 4, Received: 4, AvgRTT: degradedAvgRTT}, StatusHealthy},
+		{"slow", PingResult{Sent: 4, Received: 4, AvgRTT: 200 * time.Millisecond}, StatusDegraded},
+		{"lossy", PingResult{Sent: 4, Received: 3, Lost: 1, AvgRTT: 20 * time.Millisecond}, StatusDegraded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyResult(tt.result); got != tt.want {
+				t.Errorf("classifyResult() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	healthy := PingResult{Target: "a.example", Sent: 4, Received: 4, AvgRTT: 20 * time.Millisecond}
+	degraded := PingResult{Target: "b.example", Sent: 4, Received: 3, Lost: 1, AvgRTT: 20 * time.Millisecond}
+	down := PingResult{Target: "c.example", Mode: pingModeTCP, Sent: 4, Lost: 4}
+	unprobed := PingResult{Target: "d.example", Cancelled: true}
+
+	tests := []struct {
+		name    string
+		failOn  string
+		results []PingResult
+		wantErr string // "" expects success
+	}{
+		{"all healthy", "degraded", []PingResult{healthy}, ""},
+		{"degraded passes --fail-on=down", "down", []PingResult{healthy, degraded}, ""},
+		{"down fails --fail-on=down", "down", []PingResult{healthy, degraded, down}, "c.example (tcp) is down"},
+		{"degraded fails --fail-on=degraded", "degraded", []PingResult{degraded, down}, "b.example is degraded, c.example (tcp) is down"},
+		{"nothing fails --fail-on=none", "none", []PingResult{degraded, down}, ""},
+		{"interrupted before probing", "down", []PingResult{healthy, unprobed}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failOn, err := parseFailOn(tt.failOn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = checkHealth(tt.results, failOn)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkHealth() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUnhealthyTarget) || !strings.HasSuffix(err.Error(), tt.wantErr) {
+				t.Errorf("checkHealth() = %v, want %v ending in %q", err, ErrUnhealthyTarget, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Package core tcpping.go
+package core
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// tcpProber measures the time to complete a TCP handshake with addr. It is
+// used where ICMP is filtered or deprioritized, and includes the connect
+// overhead that ICMP does not.
+type tcpProber struct {
+	addr string // host:port
+}
+
+func (p *tcpProber) ping(timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", p.addr, timeout)
+	if err != nil {
+		return 0, fmt.Errorf("connecting to %s: %w", p.addr, err)
+	}
+	rtt := time.Since(start)
+	conn.Close()
+	return rtt, nil
+}
@@ -0,0 +1,62 @@
+// Package core template.go
+package core
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+// PingReport is the data a ping --template is executed with
+type PingReport struct {
+	Config  *PingConfig
+	Results []PingResult
+}
+
+// DownloadReport is the data a download --template is executed with
+type DownloadReport struct {
+	Config *DownloadConfig
+	Stats  DownloadStats
+}
+
+// UploadReport is the data an upload --template is executed with
+type UploadReport struct {
+	Config *UploadConfig
+	Stats  UploadStats
+}
+
+// templateFuncs are the helpers available to --template files
+var templateFuncs = template.FuncMap{
+	"ms":     func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 },
+	"mb":     func(bytes int64) float64 { return float64(bytes) / (1024 * 1024) },
+	"loss":   func(r PingResult) float64 { return r.lossPercent() },
+	"status": func(r PingResult) string { return classifyResult(r).String() },
+	"label":  func(r PingResult) string { return r.label() },
+}
+
+// templateEncoder renders results with a user-supplied text/template
+type templateEncoder struct {
+	tmpl *template.Template
+}
+
+func newTemplateEncoder(path string) (Encoder, error) {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading template: %w", err)
+	}
+	return templateEncoder{tmpl: tmpl}, nil
+}
+
+func (e templateEncoder) EncodePing(w io.Writer, config *PingConfig, results []PingResult) error {
+	return e.tmpl.Execute(w, PingReport{Config: config, Results: results})
+}
+
+func (e templateEncoder) EncodeDownload(w io.Writer, config *DownloadConfig, stats DownloadStats) error {
+	return e.tmpl.Execute(w, DownloadReport{Config: config, Stats: stats})
+}
+
+func (e templateEncoder) EncodeUpload(w io.Writer, config *UploadConfig, stats UploadStats) error {
+	return e.tmpl.Execute(w, UploadReport{Config: config, Stats: stats})
+}
@@ -0,0 +1,60 @@
+// Package core term.go
+package core
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// keyCtrlC is the byte Ctrl-C sends while the terminal is in raw mode
+const keyCtrlC = 0x03
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// terminalWidth returns the column count of the terminal attached to fd
+func terminalWidth(fd int) (int, error) {
+	width, _, err := term.GetSize(fd)
+	return width, err
+}
+
+// makeRaw puts the terminal on f in raw mode so single key presses can be
+// read as they are typed. Raw mode also stops Ctrl-C from raising a signal
+// and "\n" from returning to the left margin, so key readers watch for
+// keyCtrlC and output goes through rawWriter. The returned function restores
+// the previous terminal state.
+func makeRaw(f *os.File) (func(), error) {
+	fd := int(f.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		_ = term.Restore(fd, state)
+	}, nil
+}
+
+// rawWriter returns w, ending its lines with "\r\n" when it is a terminal,
+// for output written while makeRaw is in effect
+func rawWriter(w io.Writer) io.Writer {
+	if f, ok := w.(*os.File); ok && isTerminal(f) {
+		return crlfWriter{f}
+	}
+	return w
+}
+
+// crlfWriter turns each "\n" written to w into "\r\n"
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCRLFWriter(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no newline", "abc", "abc"},
+		{"lines", "a\nb\n", "a\r\nb\r\n"},
+		{"progress", "\r12.3 Mbps\033[K", "\r12.3 Mbps\033[K"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := crlfWriter{&buf}.Write([]byte(tt.in))
+			if err != nil || n != len(tt.in) {
+				t.Fatalf("Write = %d, %v, want %d, nil", n, err, len(tt.in))
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRawWriterLeavesNonTerminals(t *testing.T) {
+	var buf bytes.Buffer
+	if w := rawWriter(&buf); w != &buf {
+		t.Errorf("rawWriter wrapped a buffer as %T", w)
+	}
+	if w := (*pauseController)(nil).writer(&buf); w != &buf {
+		t.Errorf("nil pauseController wrapped a buffer as %T", w)
+	}
+}
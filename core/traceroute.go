@@ -0,0 +1,181 @@
+// Package core traceroute.go
+package core
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"speedgo/commands"
+)
+
+// reverseLookupTimeout bounds the hostname lookup of each hop so a slow
+// resolver does not stall the trace
+const reverseLookupTimeout = 2 * time.Second
+
+// TraceConfig holds the settings of the trace command
+type TraceConfig struct {
+	Target  string
+	MaxHops int
+	Queries int // Probes per hop
+	Timeout time.Duration
+	Numeric bool // Skip reverse DNS lookups
+	IPv6    bool
+}
+
+// HopProbe is the outcome of one probe at a hop. Address is empty when
+// nothing answered before the timeout.
+type HopProbe struct {
+	Address string
+	RTT     time.Duration
+}
+
+// Hop holds the probes sent with one TTL
+type Hop struct {
+	TTL     int
+	Probes  []HopProbe
+	Reached bool // The target itself answered
+}
+
+func parseTraceConfig(args []string) (*TraceConfig, error) {
+	cmd := commands.TraceCmd
+	if err := cmd.Parse(args); err != nil {
+		return nil, fmt.Errorf("parsing trace arguments: %w", err)
+	}
+	if err := applyFlagSources(cmd); err != nil {
+		return nil, err
+	}
+
+	config := &TraceConfig{
+		Target:  cmd.Lookup("target").Value.String(),
+		MaxHops: cmd.Lookup("max-hops").Value.(flag.Getter).Get().(int),
+		Queries: cmd.Lookup("queries").Value.(flag.Getter).Get().(int),
+		Timeout: cmd.Lookup("timeout").Value.(flag.Getter).Get().(time.Duration),
+		Numeric: cmd.Lookup("numeric").Value.(flag.Getter).Get().(bool),
+		IPv6:    cmd.Lookup("ipv6").Value.(flag.Getter).Get().(bool),
+	}
+	if config.Target == "" {
+		config.Target = cmd.Arg(0)
+	}
+	if config.Target == "" {
+		return nil, errors.New("no target given (use --target or pass it as an argument)")
+	}
+	if config.MaxHops < 1 || config.MaxHops > maxTTL {
+		return nil, fmt.Errorf("invalid --max-hops %d (expected 1-%d)", config.MaxHops, maxTTL)
+	}
+	if config.Queries < 1 {
+		return nil, errors.New("--queries must be at least 1")
+	}
+	return config, nil
+}
+
+// RunTrace prints the routers on the path to a target by sending echo
+// requests with increasing TTLs and collecting the Time Exceeded replies
+func RunTrace(ctx context.Context, args []string) error {
+	config, err := parseTraceConfig(args)
+	if err != nil {
+		return err
+	}
+
+	ipAddr, err := net.ResolveIPAddr(resolveNetwork(config.IPv6), config.Target)
+	if err != nil {
+		return fmt.Errorf("resolving address: %w", err)
+	}
+
+	v6 := ipAddr.IP.To4() == nil
+	listenNetwork, listenAddr := "ip4:icmp", net.IPv4zero
+	if v6 {
+		listenNetwork, listenAddr = "ip6:ipv6-icmp", net.IPv6unspecified
+	}
+	conn, err := net.ListenIP(listenNetwork, &net.IPAddr{IP: listenAddr})
+	if err != nil {
+		return fmt.Errorf("creating ICMP connection: %w", err)
+	}
+	defer conn.Close()
+
+	fmt.Printf("Tracing route to %s (%s), %d hops max\n", config.Target, ipAddr, config.MaxHops)
+
+	session := newPingSession(conn, ipAddr.String(), v6)
+	for ttl := 1; ttl <= config.MaxHops; ttl++ {
+		hop, err := traceHop(ctx, session, ttl, config)
+		if err != nil {
+			return err
+		}
+		printHop(ctx, hop, config.Numeric)
+		if hop.Reached {
+			return nil
+		}
+	}
+	fmt.Printf("Target not reached within %d hops\n", config.MaxHops)
+	return nil
+}
+
+// traceHop sends config.Queries probes with the given TTL
+func traceHop(ctx context.Context, session *pingSession, ttl int, config *TraceConfig) (Hop, error) {
+	hop := Hop{TTL: ttl}
+	if err := setTTL(session.conn, session.v6, ttl); err != nil {
+		return hop, err
+	}
+
+	for i := 0; i < config.Queries; i++ {
+		if err := ctx.Err(); err != nil {
+			return hop, err
+		}
+
+		rtt, err := session.ping(config.Timeout)
+		var exceeded *timeExceededError
+		switch {
+		case err == nil:
+			hop.Probes = append(hop.Probes, HopProbe{Address: session.target, RTT: rtt})
+			hop.Reached = true
+		case errors.As(err, &exceeded):
+			hop.Probes = append(hop.Probes, HopProbe{Address: exceeded.router, RTT: rtt})
+		case isTimeout(err):
+			hop.Probes = append(hop.Probes, HopProbe{})
+		default:
+			return hop, err
+		}
+	}
+	return hop, nil
+}
+
+// printHop writes one traceroute line, naming each responding router
+// before the first RTT it answered
+func printHop(ctx context.Context, hop Hop, numeric bool) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%3d ", hop.TTL)
+
+	last := ""
+	for _, probe := range hop.Probes {
+		if probe.Address == "" {
+			b.WriteString(" *")
+			continue
+		}
+		if probe.Address != last {
+			fmt.Fprintf(&b, " %s", hopName(ctx, probe.Address, numeric))
+			last = probe.Address
+		}
+		fmt.Fprintf(&b, "  %.3fms", float64(probe.RTT.Microseconds())/1000)
+	}
+	fmt.Println(b.String())
+}
+
+// hopName formats a router as "hostname (address)", or just the address
+// when it has no reverse DNS name or lookups are disabled
+func hopName(ctx context.Context, address string, numeric bool) string {
+	if numeric {
+		return address
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reverseLookupTimeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, address)
+	if err != nil || len(names) == 0 {
+		return address
+	}
+	return fmt.Sprintf("%s (%s)", strings.TrimSuffix(names[0], "."), address)
+}
@@ -0,0 +1,329 @@
+// Package core transport.go
+package core
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// newTLSConfig builds the client TLS settings shared by the transfer
+// commands. A non-empty ciphers list restricts the cipher suites that may be
+// negotiated.
+func newTLSConfig(requireTLS13 bool, ciphers []uint16) *tls.Config {
+	config := &tls.Config{}
+	if requireTLS13 {
+		config.MinVersion = tls.VersionTLS13
+	}
+	if len(ciphers) > 0 {
+		// CipherSuites only applies up to TLS 1.2; crypto/tls always offers
+		// every TLS 1.3 suite, so the negotiated suite is checked as well
+		config.CipherSuites = ciphers
+		if !slices.ContainsFunc(ciphers, isTLS13Cipher) {
+			config.MaxVersion = tls.VersionTLS12
+		}
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if !slices.Contains(ciphers, state.CipherSuite) {
+				return fmt.Errorf("server negotiated %s, which is not in --tls-ciphers",
+					tls.CipherSuiteName(state.CipherSuite))
+			}
+			return nil
+		}
+	}
+	return config
+}
+
+// HTTP versions accepted by --http. The empty value leaves the choice to
+// ALPN negotiation, which prefers HTTP/2 when the server offers it.
+const (
+	httpVersionAuto = ""
+	httpVersion11   = "1.1"
+	httpVersion2    = "2"
+)
+
+// parseHTTPVersion validates an --http value
+func parseHTTPVersion(version string) (string, error) {
+	switch version {
+	case httpVersionAuto, httpVersion11, httpVersion2:
+		return version, nil
+	case "1", "http/1.1":
+		return httpVersion11, nil
+	case "2.0", "h2", "http/2":
+		return httpVersion2, nil
+	default:
+		return "", fmt.Errorf("invalid --http %q (expected 1.1 or 2)", version)
+	}
+}
+
+// wrapTLSError explains handshake failures caused by --require-tls13 or
+// --tls-ciphers
+func wrapTLSError(err error, requireTLS13, restrictCiphers bool) error {
+	var alert tls.AlertError
+	if !errors.As(err, &alert) {
+		return err
+	}
+	switch {
+	case requireTLS13:
+		return fmt.Errorf("server did not negotiate TLS 1.3 (required by --require-tls13): %w", err)
+	case restrictCiphers:
+		return fmt.Errorf("server did not accept any cipher suite allowed by --tls-ciphers: %w", err)
+	}
+	return err
+}
+
+// parseCipherSuites maps a comma-separated list of cipher suite names, as
+// spelled by crypto/tls (e.g. TLS_AES_128_GCM_SHA256), to their IDs
+func parseCipherSuites(list string, requireTLS13 bool) ([]uint16, error) {
+	names := splitAndTrim(list, ",")
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	if requireTLS13 && !slices.ContainsFunc(ids, isTLS13Cipher) {
+		return nil, errors.New("--tls-ciphers lists no TLS 1.3 cipher suite but --require-tls13 is set")
+	}
+	return ids, nil
+}
+
+// isTLS13Cipher reports whether id is a TLS 1.3 cipher suite
+func isTLS13Cipher(id uint16) bool {
+	for _, suite := range tls.CipherSuites() {
+		if suite.ID == id {
+			return slices.Contains(suite.SupportedVersions, tls.VersionTLS13)
+		}
+	}
+	return false
+}
+
+// describeProtocol returns the HTTP version of a response and, for HTTPS,
+// the negotiated TLS version and cipher suite
+func describeProtocol(resp *http.Response) string {
+	if resp.TLS == nil {
+		return resp.Proto
+	}
+	return fmt.Sprintf("%s over %s with %s", resp.Proto,
+		tls.VersionName(resp.TLS.Version), tls.CipherSuiteName(resp.TLS.CipherSuite))
+}
+
+// protocolSet records the protocol versions negotiated by the workers
+type protocolSet struct {
+	mu     sync.Mutex
+	protos map[string]bool
+}
+
+// add records a protocol and reports whether it had not been seen before
+func (p *protocolSet) add(proto string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.protos == nil {
+		p.protos = make(map[string]bool)
+	}
+	if p.protos[proto] {
+		return false
+	}
+	p.protos[proto] = true
+	return true
+}
+
+func (p *protocolSet) list() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]string, 0, len(p.protos))
+	for proto := range p.protos {
+		result = append(result, proto)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// maxRetryAfter caps how long a worker honors a server's Retry-After
+const maxRetryAfter = 30 * time.Second
+
+// rateLimitError reports a 429 response and how long the server asked
+// clients to wait before retrying
+type rateLimitError struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by server (retry after %v)", e.retryAfter)
+}
+
+// checkRateLimit returns a rateLimitError for 429 responses
+func checkRateLimit(resp *http.Response, fallback time.Duration) error {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	return &rateLimitError{
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now(), fallback),
+	}
+}
+
+// parseRetryAfter reads a Retry-After value given either as delay seconds
+// or as an HTTP date, capped at maxRetryAfter
+func parseRetryAfter(value string, now time.Time, fallback time.Duration) time.Duration {
+	delay := fallback
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+	}
+
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+	return delay
+}
+
+// backoffFor returns how long a worker should wait after err, honoring any
+// delay requested by the server
+func backoffFor(err error, fallback time.Duration) (time.Duration, bool) {
+	var limited *rateLimitError
+	if errors.As(err, &limited) {
+		return limited.retryAfter, true
+	}
+	return fallback, false
+}
+
+// retryBackoff returns the delay before the next attempt after failures
+// consecutive errors: initial, doubling with every further failure, up to
+// limit
+func retryBackoff(failures int, initial, limit time.Duration) time.Duration {
+	backoff := initial
+	for i := 1; i < failures && backoff < limit; i++ {
+		backoff *= 2
+	}
+	return min(backoff, limit)
+}
+
+// RequestPhases splits the time spent in HTTP requests into connection setup
+// (DNS lookup, TCP connect and TLS handshake) and the transfer that follows
+type RequestPhases struct {
+	Requests       int64
+	NewConnections int64
+	Setup          time.Duration
+	Total          time.Duration
+}
+
+// SetupFraction returns the share of request time spent establishing connections
+func (p RequestPhases) SetupFraction() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float64(p.Setup) / float64(p.Total)
+}
+
+// phaseRecorder accumulates RequestPhases across the workers of a run
+type phaseRecorder struct {
+	clock          Clock // nil uses the system clock
+	requests       int64
+	newConnections int64
+	setupNanos     int64
+	totalNanos     int64
+}
+
+// trace attaches an httptrace to req that times connection setup. The
+// returned function must be called once the request is finished, including
+// reading the response body.
+func (p *phaseRecorder) trace(req *http.Request) (*http.Request, func()) {
+	clock := clockOrReal(p.clock)
+	start := clock.Now()
+	var setupNanos int64
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			atomic.StoreInt64(&setupNanos, int64(clock.Since(start)))
+			if !info.Reused {
+				atomic.AddInt64(&p.newConnections, 1)
+			}
+		},
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	return req, func() {
+		atomic.AddInt64(&p.requests, 1)
+		atomic.AddInt64(&p.setupNanos, atomic.LoadInt64(&setupNanos))
+		atomic.AddInt64(&p.totalNanos, int64(clock.Since(start)))
+	}
+}
+
+func (p *phaseRecorder) snapshot() RequestPhases {
+	return RequestPhases{
+		Requests:       atomic.LoadInt64(&p.requests),
+		NewConnections: atomic.LoadInt64(&p.newConnections),
+		Setup:          time.Duration(atomic.LoadInt64(&p.setupNanos)),
+		Total:          time.Duration(atomic.LoadInt64(&p.totalNanos)),
+	}
+}
+
+func printRequestPhases(w io.Writer, phases RequestPhases) {
+	if phases.Requests == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Connection setup: %.1f%% of request time (%d new connections over %d requests)\n",
+		phases.SetupFraction()*100, phases.NewConnections, phases.Requests)
+}
+
+// cacheBustURL appends a random query parameter so that neither a CDN nor a
+// deduplicating proxy can answer the request from cache
+func cacheBustURL(rawURL string) string {
+	token := make([]byte, 8)
+	rand.Read(token)
+
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + "nocache=" + hex.EncodeToString(token)
+}
+
+// cacheStatus reports whether a response says it came from a cache, and
+// whether it carried any header that says either way
+func cacheStatus(resp *http.Response) (hit, reported bool) {
+	if age := resp.Header.Get("Age"); age != "" {
+		reported = true
+		if seconds, err := strconv.Atoi(age); err == nil && seconds > 0 {
+			hit = true
+		}
+	}
+	for _, header := range []string{"X-Cache", "CF-Cache-Status"} {
+		if value := resp.Header.Get(header); value != "" {
+			reported = true
+			if strings.Contains(strings.ToUpper(value), "HIT") {
+				hit = true
+			}
+		}
+	}
+	return hit, reported
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	const fallback = time.Second
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"missing", "", fallback},
+		{"delay seconds", "20", 20 * time.Second},
+		{"padded seconds", " 5 ", 5 * time.Second},
+		{"zero seconds", "0", 0},
+		{"negative seconds", "-5", fallback},
+		{"http date", now.Add(15 * time.Second).Format(http.TimeFormat), 15 * time.Second},
+		{"date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"capped seconds", "3600", maxRetryAfter},
+		{"capped date", now.Add(time.Hour).Format(http.TimeFormat), maxRetryAfter},
+		{"garbage", "soon", fallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now, fallback); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	tests := []struct {
+		status      int
+		retryAfter  string
+		wantLimited bool
+		wantBackoff time.Duration
+	}{
+		{http.StatusOK, "", false, 2 * time.Second},
+		{http.StatusServiceUnavailable, "10", false, 2 * time.Second},
+		{http.StatusTooManyRequests, "10", true, 10 * time.Second},
+		{http.StatusTooManyRequests, "", true, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %q", tt.status, tt.retryAfter), func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+			backoff, limited := backoffFor(checkRateLimit(resp, 2*time.Second), 2*time.Second)
+			if limited != tt.wantLimited || backoff != tt.wantBackoff {
+				t.Errorf("backoff, limited = %v, %v, want %v, %v", backoff, limited, tt.wantBackoff, tt.wantLimited)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Package core ttl.go
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// maxTTL is the largest value of the 8-bit IPv4 TTL / IPv6 hop limit field
+const maxTTL = 255
+
+// timeExceededError reports that a probe's TTL ran out at router before it
+// reached the target. The probe's RTT is returned alongside it.
+type timeExceededError struct {
+	seq    int
+	router string
+}
+
+func (e *timeExceededError) Error() string {
+	return fmt.Sprintf("TTL exceeded in transit at %s", e.router)
+}
+
+// setTTL sets the TTL (IPv6 hop limit) of every packet sent on conn
+func setTTL(conn *net.IPConn, v6 bool, ttl int) error {
+	var err error
+	if v6 {
+		err = ipv6.NewPacketConn(conn).SetHopLimit(ttl)
+	} else {
+		err = ipv4.NewPacketConn(conn).SetTTL(ttl)
+	}
+	if err != nil {
+		return fmt.Errorf("setting TTL %d: %w", ttl, err)
+	}
+	return nil
+}
+
+// quotedEcho extracts the echo ID and sequence from the original datagram
+// quoted in an ICMP error message: the IP header of the probe followed by at
+// least the first 8 bytes of its ICMP header
+func quotedEcho(quoted []byte, v6 bool) (id, seq int, ok bool) {
+	headerLen := ipv6.HeaderLen
+	if !v6 {
+		if len(quoted) < ipv4.HeaderLen {
+			return 0, 0, false
+		}
+		headerLen = int(quoted[0]&0x0f) * 4
+		if headerLen < ipv4.HeaderLen {
+			return 0, 0, false
+		}
+	}
+	if len(quoted) < headerLen+8 {
+		return 0, 0, false
+	}
+	echo := quoted[headerLen:]
+	return int(binary.BigEndian.Uint16(echo[4:6])), int(binary.BigEndian.Uint16(echo[6:8])), true
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestQuotedEcho(t *testing.T) {
+	quotedIPv6 := make([]byte, ipv6.HeaderLen+8)
+	quotedIPv6[0] = 0x60
+	quotedIPv6[ipv6.HeaderLen] = 128 // Echo request
+	binary.BigEndian.PutUint16(quotedIPv6[ipv6.HeaderLen+4:], 0xbeef)
+	binary.BigEndian.PutUint16(quotedIPv6[ipv6.HeaderLen+6:], 300)
+
+	tests := []struct {
+		name    string
+		quoted  []byte
+		v6      bool
+		wantID  int
+		wantSeq int
+		wantOK  bool
+	}{
+		{"ipv4", quotedIPv4Probe(5, 0x1234, 9), false, 0x1234, 9, true},
+		{"ipv4 with options", quotedIPv4Probe(15, 77, 1), false, 77, 1, true},
+		{"ipv6", quotedIPv6, true, 0xbeef, 300, true},
+		{"ipv4 cut inside the echo header", quotedIPv4Probe(5, 1, 2)[:25], false, 0, 0, false},
+		{"ipv4 shorter than a header", make([]byte, 10), false, 0, 0, false},
+		{"ipv4 header length below minimum", quotedIPv4Probe(4, 1, 2), false, 0, 0, false},
+		{"ipv6 cut inside the echo header", quotedIPv6[:ipv6.HeaderLen+4], true, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, seq, ok := quotedEcho(tt.quoted, tt.v6)
+			if ok != tt.wantOK || id != tt.wantID || seq != tt.wantSeq {
+				t.Errorf("quotedEcho = %d, %d, %v, want %d, %d, %v", id, seq, ok, tt.wantID, tt.wantSeq, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Package core units.go
+package core
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteUnits maps size suffixes to multipliers. Decimal units follow the SI
+// definition, binary units the IEC one.
+var byteUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"KIB", 1 << 10},
+	{"MIB", 1 << 20},
+	{"GIB", 1 << 30},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// parseByteSize parses sizes such as "500", "64KiB" or "50MB"
+func parseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// minSpeedDuration is the shortest elapsed time a speed is computed over.
+// Runs that fail instantly end well below it, where the division would
+// produce huge, NaN or infinite rates.
+const minSpeedDuration = time.Millisecond
+
+// mbps converts bytes transferred over d to megabits per second, returning
+// 0 when d is too short for the rate to be meaningful
+func mbps(bytes int64, d time.Duration) float64 {
+	if d < minSpeedDuration {
+		return 0
+	}
+	speed := float64(bytes*8) / (1000 * 1000 * d.Seconds())
+	if math.IsNaN(speed) || math.IsInf(speed, 0) {
+		return 0
+	}
+	return speed
+}
+
+// Speed display units accepted by --unit. Speeds are always measured in
+// Mbps; the unit only changes how they are printed.
+const (
+	unitMbps  = "mbps"
+	unitMiBps = "mibps"
+	unitBoth  = "both"
+)
+
+// parseSpeedUnit validates a --unit value
+func parseSpeedUnit(unit string) (string, error) {
+	switch unit := strings.ToLower(unit); unit {
+	case unitMbps, unitMiBps, unitBoth:
+		return unit, nil
+	default:
+		return "", fmt.Errorf("invalid --unit %q (expected mbps, mibps or both)", unit)
+	}
+}
+
+// mbpsToMiBps converts megabits per second to mebibytes per second
+func mbpsToMiBps(mbps float64) float64 {
+	return mbps * 1000 * 1000 / 8 / (1024 * 1024)
+}
+
+// formatSpeed renders a speed in Mbps in the chosen --unit
+func formatSpeed(mbps float64, unit string) string {
+	switch unit {
+	case unitMiBps:
+		return fmt.Sprintf("%.2f MiB/s", mbpsToMiBps(mbps))
+	case unitBoth:
+		return fmt.Sprintf("%.2f Mbps (%.2f MiB/s)", mbps, mbpsToMiBps(mbps))
+	default:
+		return fmt.Sprintf("%.2f Mbps", mbps)
+	}
+}
+
+// speedText formats an average speed, explaining a missing value when the
+// run ended too quickly to measure one
+func speedText(speed float64, d time.Duration, unit string) string {
+	if d < minSpeedDuration {
+		return fmt.Sprintf("n/a (run ended after %v, too short to measure)", d)
+	}
+	return formatSpeed(speed, unit)
+}
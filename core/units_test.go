@@ -0,0 +1,96 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSpeedUnit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"mbps", unitMbps, false},
+		{"MiBps", unitMiBps, false},
+		{"BOTH", unitBoth, false},
+		{"kbps", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSpeedUnit(tt.in)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "invalid --unit") {
+					t.Errorf("parseSpeedUnit(%q) error = %v, want an invalid --unit error", tt.in, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseSpeedUnit(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSpeed(t *testing.T) {
+	tests := []struct {
+		mbps float64
+		unit string
+		want string
+	}{
+		{100, unitMbps, "100.00 Mbps"},
+		{100, unitMiBps, "11.92 MiB/s"},
+		{100, unitBoth, "100.00 Mbps (11.92 MiB/s)"},
+		{8.388608, unitMiBps, "1.00 MiB/s"},
+		{0, unitBoth, "0.00 Mbps (0.00 MiB/s)"},
+		{100, "furlongs", "100.00 Mbps"}, // Unknown units were rejected by parseSpeedUnit
+	}
+	for _, tt := range tests {
+		if got := formatSpeed(tt.mbps, tt.unit); got != tt.want {
+			t.Errorf("formatSpeed(%v, %q) = %q, want %q", tt.mbps, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestSpeedText(t *testing.T) {
+	tests := []struct {
+		speed float64
+		d     time.Duration
+		unit  string
+		want  string
+	}{
+		{50, 10 * time.Second, unitMbps, "50.00 Mbps"},
+		{50, 10 * time.Second, unitMiBps, "5.96 MiB/s"},
+		{50, time.Millisecond, unitBoth, "50.00 Mbps (5.96 MiB/s)"},
+		{0, 500 * time.Microsecond, unitMbps, "n/a (run ended after 500µs, too short to measure)"},
+		{0, 0, unitMiBps, "n/a (run ended after 0s, too short to measure)"},
+	}
+	for _, tt := range tests {
+		if got := speedText(tt.speed, tt.d, tt.unit); got != tt.want {
+			t.Errorf("speedText(%v, %v, %q) = %q, want %q", tt.speed, tt.d, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestMbps(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		d     time.Duration
+		want  float64
+	}{
+		{0, 0, 0},
+		{1 << 20, 0, 0},
+		{1 << 20, 500 * time.Microsecond, 0},
+		{1 << 20, -time.Second, 0},
+		{0, time.Second, 0},
+		{1_250_000, time.Second, 10},
+		{125_000, time.Millisecond, 1000},
+	}
+	for _, tt := range tests {
+		if got := mbps(tt.bytes, tt.d); got != tt.want {
+			t.Errorf("mbps(%d, %v) = %v, want %v", tt.bytes, tt.d, got, tt.want)
+		}
+	}
+}
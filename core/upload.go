@@ -3,13 +3,18 @@ package core
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/rand"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"speedgo/commands"
+	"speedgo/core/stats"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -17,9 +22,44 @@ import (
 )
 
 type UploadConfig struct {
-	Duration    time.Duration
-	Concurrency int
-	Verbose     bool
+	URL          string // Endpoint the payload is POSTed to
+	Duration     time.Duration
+	Warmup       time.Duration // Start of the test excluded from the speed
+	Unit         string        // Speed display unit: mbps, mibps or both
+	HTTPVersion  string        // Forced HTTP version, or "" to negotiate
+	Concurrency  int
+	Verbose      bool
+	Seed         int64 // Non-zero seeds the package RNG for reproducible payloads
+	RequireTLS13 bool
+	TLSCiphers   []uint16 // Allowed cipher suites; empty allows the Go defaults
+	Insecure     bool     // Skip TLS certificate verification
+	Proxy        *url.URL // Proxy for all requests; nil falls back to the environment
+	Format       string
+	Template     string // text/template file used instead of Format
+	File         string // Upload this file's contents instead of generated data
+	ChunkSize    int64  // Bytes sent per request
+	MaxBytes     int64  // Stop once this many bytes were sent; 0 is no cap
+	Data         string // Generated payload content: random or zeros
+	Compress     bool   // Gzip request bodies on the fly
+	Out          string // Results destination: "-" (stdout), "stderr" or a file path
+	Clock        Clock  // Time source for speed calculations; nil uses the system clock
+	Server       int    // Server list ID to test against, 0 for URL
+	ServerList   string // Server list URL for Server
+
+	MinSpeed float64       // Fail the run below this speed in Mbps; 0 disables the check
+	Repeat   int           // Number of runs
+	Interval time.Duration // Time from the start of one run to the next
+	Log      string        // JSON Lines file each run is appended to, or ""
+
+	pause *pauseController // Space bar pause control, set on interactive terminals
+
+	// Bufferbloat measures the RTT to BufferbloatTarget (default: the
+	// upload host) before and during the test
+	Bufferbloat       bool
+	BufferbloatTarget string
+
+	Burst bool // Upload for burstWindow only and report the peak sample rate
+	RPS   bool // Send small requests and report requests/second and latency
 }
 
 type UploadStats struct {
@@ -27,11 +67,51 @@ type UploadStats struct {
 	Duration  time.Duration
 	Speed     float64
 	Error     error
+	Protocols []string // HTTP and TLS versions negotiated with the server
+
+	RateLimited int64 // Number of 429 responses received
+	Phases      RequestPhases
+
+	// LogicalBytes is the payload size before compression; it equals
+	// BytesSent unless --compress is used
+	LogicalBytes int64
+
+	Latency *LoadedLatency // Set when --bufferbloat is used
+
+	PeakSpeed float64 // Highest per-burstSampleInterval rate in Mbps, set with --burst
+
+	// Set with --rps: completed requests, their rate and latency percentiles
+	Requests          int64
+	RequestsPerSecond float64
+	RequestP50        time.Duration
+	RequestP95        time.Duration
+
+	Workers []WorkerStats // Bytes and speed of each worker
+
+	// DataUsed counts every byte sent, including the warmup and requests
+	// cut off at the end, and DataCapped is set when --max-bytes stopped
+	// the test
+	DataUsed   int64
+	DataCapped bool
+
+	Cancelled bool // Interrupted before the duration ended; the stats are partial
 }
 
+// uploadRequestTimeout bounds each upload request, which carries one chunk
+const uploadRequestTimeout = 10 * time.Second
+
+// rpsChunkSize is the request body used by --rps unless --chunk-size is
+// given, and requestLatencySample bounds the latencies kept for percentiles
+const (
+	rpsChunkSize         = 1024
+	requestLatencySample = 4096
+)
+
+// Burst mode uploads for a short window and samples the wire byte count at
+// a fine resolution to find the peak rate
 const (
-	uploadEndpoint = "https://speed.cloudflare.com/__up"
-	chunkSize      = 1 * 1024 * 1024 // 1MB chunks
+	burstWindow         = 2 * time.Second
+	burstSampleInterval = 100 * time.Millisecond
 )
 
 func RunUpload(ctx context.Context, args []string) error {
@@ -40,29 +120,194 @@ func RunUpload(ctx context.Context, args []string) error {
 		return fmt.Errorf("parsing upload config: %w", err)
 	}
 
-	fmt.Printf("Starting upload speed test (Duration: %v, Concurrent streams: %d)\n",
-		config.Duration, config.Concurrency)
+	encoder, err := newEncoder(config.Format, config.Template)
+	if err != nil {
+		return err
+	}
+	if config.Insecure {
+		warnInsecure()
+	}
+
+	if config.Server != 0 {
+		server, err := findServer(ctx, speedClientConfig{
+			RequireTLS13: config.RequireTLS13,
+			TLSCiphers:   config.TLSCiphers,
+			Insecure:     config.Insecure,
+			Proxy:        config.Proxy,
+		}, config.ServerList, config.Server)
+		if err != nil {
+			return err
+		}
+		config.URL = server.UploadURL
+		fmt.Fprintf(bannerWriter(config.Format), "Testing against server %d: %s (%s)\n", server.ID, server.Name, server.Host)
+	}
+
+	fmt.Fprintf(bannerWriter(config.Format), "Starting upload speed test to %s (Duration: %v, Concurrent streams: %d)\n",
+		config.URL, config.Duration, config.Concurrency)
+	if config.File != "" {
+		fmt.Fprintf(bannerWriter(config.Format), "Uploading contents of %s\n", config.File)
+	}
+
+	pause, restore := startPauseControl(clockOrReal(config.Clock), bannerWriter(config.Format))
+	defer restore()
+	config.pause = pause
+
+	if config.Repeat > 1 {
+		return runUploadRepeated(ctx, config, encoder)
+	}
 
 	stats := measureUploadSpeed(ctx, config)
-	printUploadResults(stats)
+	err = writeResults(config.Out, func(w io.Writer) error {
+		return encoder.EncodeUpload(config.pause.writer(w), config, stats)
+	})
+	if err != nil {
+		return err
+	}
+	if err := logResults(config.Log, uploadRecord(time.Now(), stats)); err != nil {
+		return err
+	}
+	return checkUpload(config, stats)
+}
+
+// runUploadRepeated measures config.Repeat times, config.Interval apart,
+// and follows the results of every run with a summary of their speeds. A
+// run slower than --min-upload fails the command.
+func runUploadRepeated(ctx context.Context, config *UploadConfig, encoder Encoder) error {
+	var runs []UploadStats
+	first, rest := repeatEncoders(encoder)
+	err := writeResults(config.Out, func(w io.Writer) error {
+		var previous time.Duration
+		for run := 1; run <= config.Repeat; run++ {
+			encoder := first
+			if run > 1 {
+				encoder = rest
+				if err := waitForRun(ctx, config.pause.writer(bannerWriter(config.Format)), run, config.Repeat, config.Interval, previous); err != nil {
+					break
+				}
+			}
+			start := time.Now()
+			stats := measureUploadSpeed(ctx, config)
+			previous = time.Since(start)
+			if err := encoder.EncodeUpload(config.pause.writer(w), config, stats); err != nil {
+				return err
+			}
+			if err := logResults(config.Log, uploadRecord(time.Now(), stats)); err != nil {
+				return err
+			}
+			runs = append(runs, stats)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		speeds := make([]float64, len(runs))
+		for i, stats := range runs {
+			speeds[i] = stats.Speed
+		}
+		printRepeatSummary(summaryWriter(w, config.Format, config.Template), "upload", summarizeRuns(speeds), config.Unit)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
+	for _, stats := range runs {
+		if err := checkUpload(config, stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkUpload returns the error for a run slower than --min-upload
+func checkUpload(config *UploadConfig, stats UploadStats) error {
+	if stats.Speed < config.MinSpeed {
+		return fmt.Errorf("%w: upload %.2f Mbps, need at least %.2f Mbps",
+			ErrBelowMinSpeed, stats.Speed, config.MinSpeed)
+	}
 	return nil
 }
 
 func measureUploadSpeed(ctx context.Context, config *UploadConfig) UploadStats {
+	parent := ctx
+	var latency *LoadedLatency
+	if config.Bufferbloat {
+		target := latencyTarget(config.BufferbloatTarget, config.URL)
+		latency = &LoadedLatency{Target: target, Idle: measureIdleLatency(ctx, target)}
+	}
+
 	var totalBytes int64
-	start := time.Now()
+	clock := clockOrReal(config.Clock)
+	window := newMeasureWindow(clock, config.pause)
+	elapsed := window.elapsed
+
+	// Bytes moved during the warmup are discarded and the window restarts
+	// when it ends, so slow start does not drag the speed down
+	var warmupDone <-chan time.Time
+	if config.Warmup > 0 {
+		warmupDone = clock.After(config.Warmup)
+	}
 
 	// Create channels for coordination
 	errChan := make(chan error, config.Concurrency)
-	bytesChan := make(chan int64, config.Concurrency)
+	bytesChan := make(chan workerBytes, config.Concurrency)
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(ctx, config.Duration)
+	var cancel context.CancelFunc
+	if config.pause != nil {
+		ctx, cancel = context.WithCancel(ctx)
+		go config.pause.expire(ctx, config.Duration, cancel)
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, config.Duration)
+	}
 	defer cancel()
 
 	// Generate test data
-	testData := generateTestData(chunkSize)
+	if config.Seed != 0 {
+		seedRNG(config.Seed)
+	}
+	run := &uploadRun{
+		config: config,
+		client: newSpeedClient(speedClientConfig{
+			RequireTLS13:   config.RequireTLS13,
+			TLSCiphers:     config.TLSCiphers,
+			HTTPVersion:    config.HTTPVersion,
+			Insecure:       config.Insecure,
+			Proxy:          config.Proxy,
+			RequestTimeout: uploadRequestTimeout,
+		}),
+		phases:    phaseRecorder{clock: config.Clock},
+		protocols: &protocolSet{},
+	}
+	if config.RPS {
+		run.latencies = stats.NewReservoir[time.Duration](requestLatencySample)
+	}
+	if config.File == "" {
+		run.testData = generateTestData(int(config.ChunkSize), config.Data, config.Seed != 0)
+	} else {
+		file, err := os.Open(config.File)
+		if err != nil {
+			return UploadStats{Error: fmt.Errorf("opening upload file: %w", err)}
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return UploadStats{Error: fmt.Errorf("reading upload file info: %w", err)}
+		}
+		run.file = file
+		run.fileSize = info.Size()
+	}
+
+	var loadedLatency <-chan PingResult
+	if latency != nil {
+		loadedLatency = startLoadedLatency(ctx, latency.Target)
+	}
+
+	var burstSampler *sampler
+	if config.Burst {
+		burstSampler = startSampler(ctx, clock, &run.sentBytes, burstSampleInterval, config.pause)
+	}
 
 	// Start concurrent uploads
 	var wg sync.WaitGroup
@@ -70,29 +315,13 @@ func measureUploadSpeed(ctx context.Context, config *UploadConfig) UploadStats {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			uploadWorker(ctx, config, testData, bytesChan, errChan)
+			uploadWorker(ctx, workerID, run, bytesChan, errChan)
 		}(i)
 	}
 
 	// Start progress monitoring
-	go func() {
-		if config.Verbose {
-			ticker := time.NewTicker(time.Second)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					current := atomic.LoadInt64(&totalBytes)
-					duration := time.Since(start)
-					speed := float64(current*8) / (1000 * 1000 * duration.Seconds())
-					fmt.Printf("\rCurrent upload speed: %.2f Mbps", speed)
-				}
-			}
-		}
-	}()
+	progress := startProgress(config.Verbose, "Current upload speed", &totalBytes, elapsed, config.Unit)
+	defer progress.Stop()
 
 	// Collect results
 	go func() {
@@ -103,98 +332,328 @@ func measureUploadSpeed(ctx context.Context, config *UploadConfig) UploadStats {
 
 	// Process results
 	var lastError error
+	capped := false
+	checkCap := func() {
+		// Stop at the data cap; requests in flight are cut off
+		if config.MaxBytes > 0 && atomic.LoadInt64(&run.sentBytes) >= config.MaxBytes && !capped {
+			capped = true
+			cancel()
+		}
+	}
+	workerTotals := make([]int64, config.Concurrency)
 	for {
 		select {
+		case <-warmupDone:
+			warmupDone = nil
+			window.restart()
+			run.resetCounters()
+			if burstSampler != nil {
+				burstSampler.reset()
+			}
+			if config.Verbose {
+				fmt.Println("\nWarmup finished, measuring")
+			}
+
 		case bytes, ok := <-bytesChan:
 			if !ok {
-				duration := time.Since(start)
+				progress.Stop()
+				transferred := atomic.LoadInt64(&totalBytes)
+				duration := elapsed()
+				if latency != nil {
+					latency.Loaded = <-loadedLatency
+				}
+				var peakSpeed float64
+				if burstSampler != nil {
+					peakSpeed = peakRate(burstSampler.Samples(), burstSampleInterval)
+				}
+				requests := atomic.LoadInt64(&run.requests)
+				var requestRate float64
+				if duration >= minSpeedDuration {
+					requestRate = float64(requests) / duration.Seconds()
+				}
+				latencies := run.requestLatencies()
 				return UploadStats{
-					BytesSent: totalBytes,
+					BytesSent: transferred,
 					Duration:  duration,
-					Speed:     float64(totalBytes*8) / (1000 * 1000 * duration.Seconds()),
+					Speed:     mbps(transferred, duration),
 					Error:     lastError,
+					Protocols: run.protocols.list(),
+
+					RateLimited: atomic.LoadInt64(&run.rateLimited),
+					Phases:      run.phases.snapshot(),
+
+					LogicalBytes: atomic.LoadInt64(&run.logicalBytes),
+					Latency:      latency,
+
+					PeakSpeed: peakSpeed,
+
+					Requests:          requests,
+					RequestsPerSecond: requestRate,
+					RequestP50:        stats.Percentile(latencies, 50),
+					RequestP95:        stats.Percentile(latencies, 95),
+
+					Workers: workerBreakdown(workerTotals, duration),
+
+					DataUsed:   atomic.LoadInt64(&run.sentBytes),
+					DataCapped: capped,
+
+					Cancelled: parent.Err() != nil,
 				}
 			}
-			atomic.AddInt64(&totalBytes, bytes)
+			if warmupDone == nil {
+				atomic.AddInt64(&totalBytes, bytes.n)
+				workerTotals[bytes.worker] += bytes.n
+			}
+
+			checkCap()
 
 		case err := <-errChan:
-			if err != nil {
+			// The request bodies stop at the cap themselves, failing the
+			// requests that hit it, so errors past the cap are expected
+			checkCap()
+			if err != nil && !errors.Is(err, context.Canceled) && !capped {
 				lastError = err
 			}
 		}
 	}
 }
 
-func uploadWorker(ctx context.Context, config *UploadConfig,
-	testData []byte, bytesChan chan<- int64, errChan chan<- error) {
+// uploadRun holds the state shared by all workers of one measurement
+type uploadRun struct {
+	config      *UploadConfig
+	client      *http.Client // Shared by all workers
+	testData    []byte
+	protocols   *protocolSet
+	phases      phaseRecorder
+	rateLimited int64
+
+	logicalBytes int64 // Uncompressed bytes sent
+	sentBytes    int64 // Wire bytes read so far, including requests still in flight
+	requests     int64 // Requests that completed with 200 OK
+
+	latencyMu sync.Mutex
+	latencies *stats.Reservoir[time.Duration] // Set with --rps
+
+	file       *os.File // Set when uploading from --file
+	fileSize   int64
+	fileOffset int64 // Next read position, shared by all workers
+}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second, // Individual request timeout
-		Transport: &http.Transport{
-			MaxIdleConns:       100,
-			IdleConnTimeout:    90 * time.Second,
-			DisableCompression: true,
-			MaxConnsPerHost:    100,
-		},
-	}
+func uploadWorker(ctx context.Context, id int, run *uploadRun,
+	bytesChan chan<- workerBytes, errChan chan<- error) {
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			if err := uploadChunk(ctx, client, testData, bytesChan); err != nil {
+			if err := run.config.pause.wait(ctx); err != nil {
+				return
+			}
+			if err := uploadChunk(ctx, run, id, bytesChan); err != nil {
 				errChan <- fmt.Errorf("upload error: %w", err)
-				time.Sleep(100 * time.Millisecond) // Short backoff on error
+
+				// Short backoff on error, or as long as a 429 asked for
+				backoff, limited := backoffFor(err, 100*time.Millisecond)
+				if limited {
+					atomic.AddInt64(&run.rateLimited, 1)
+				}
+				if err := sleepCtx(ctx, backoff); err != nil {
+					return
+				}
 				continue
 			}
 		}
 	}
 }
 
-func uploadChunk(ctx context.Context, client *http.Client, data []byte, bytesChan chan<- int64) error {
+// recordRequest counts one successful request and, with --rps, samples its
+// latency
+func (r *uploadRun) recordRequest(latency time.Duration) {
+	atomic.AddInt64(&r.requests, 1)
+
+	r.latencyMu.Lock()
+	defer r.latencyMu.Unlock()
+	if r.latencies != nil {
+		r.latencies.Add(latency)
+	}
+}
+
+// requestLatencies returns the sampled request latencies, if any
+func (r *uploadRun) requestLatencies() []time.Duration {
+	r.latencyMu.Lock()
+	defer r.latencyMu.Unlock()
+	if r.latencies == nil {
+		return nil
+	}
+	return r.latencies.Values()
+}
+
+// resetCounters discards the request statistics gathered so far, when the
+// warmup ends
+func (r *uploadRun) resetCounters() {
+	atomic.StoreInt64(&r.requests, 0)
+	atomic.StoreInt64(&r.logicalBytes, 0)
+
+	r.latencyMu.Lock()
+	defer r.latencyMu.Unlock()
+	if r.latencies != nil {
+		r.latencies = stats.NewReservoir[time.Duration](requestLatencySample)
+	}
+}
+
+// payload returns the body for one upload request and its length. With
+// --file each request sends the next chunk-size slice of the file, wrapping
+// around to the start, so files smaller or larger than a chunk both work.
+func (r *uploadRun) payload() (io.Reader, int64) {
+	if r.file == nil {
+		return bytes.NewReader(r.testData), int64(len(r.testData))
+	}
+
+	size := r.config.ChunkSize
+	offset := (atomic.AddInt64(&r.fileOffset, size) - size) % r.fileSize
+
+	var sections []io.Reader
+	for remaining := size; remaining > 0; {
+		n := min(remaining, r.fileSize-offset)
+		sections = append(sections, io.NewSectionReader(r.file, offset, n))
+		remaining -= n
+		offset = 0
+	}
+	return io.MultiReader(sections...), size
+}
+
+func uploadChunk(ctx context.Context, run *uploadRun, worker int, bytesChan chan<- workerBytes) error {
+	payload, size := run.payload()
+
+	// With --compress the wire bytes are counted after gzip and the logical
+	// bytes before it
+	logical := &countingReader{reader: payload}
+	body := io.Reader(logical)
+	if run.config.Compress {
+		compressed := gzipStream(logical)
+		defer compressed.Close() // Unblocks the compressor if the request fails
+		body = compressed
+		size = -1 // Unknown until compressed, sent chunked
+	}
+
 	reader := &countingReader{
-		reader: bytes.NewReader(data),
+		reader: body,
 		count:  0,
+		total:  &run.sentBytes,
+		limit:  run.config.MaxBytes,
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", uploadEndpoint, reader)
+	req, err := http.NewRequestWithContext(ctx, "POST", run.config.URL, reader)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
 
+	req, finish := run.phases.trace(req)
+	defer finish()
+
 	req.Header.Set("Content-Type", "application/octet-stream")
-	req.Header.Set("Content-Length", fmt.Sprint(len(data)))
+	if run.config.Compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.ContentLength = size
 
-	resp, err := client.Do(req)
+	clock := clockOrReal(run.config.Clock)
+	start := clock.Now()
+	resp, err := run.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("making request: %w", err)
+		return fmt.Errorf("making request: %w", wrapProxyError(wrapTLSError(err, run.config.RequireTLS13, len(run.config.TLSCiphers) > 0), run.config.Proxy))
 	}
 	defer resp.Body.Close()
 
+	if proto := describeProtocol(resp); run.protocols.add(proto) && run.config.Verbose {
+		fmt.Printf("\nNegotiated %s (%s)\n", resp.Proto, proto)
+	}
+
+	if err := checkRateLimit(resp, time.Second); err != nil {
+		return err
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
 	}
 
+	run.recordRequest(clock.Since(start))
+
 	// Report bytes uploaded
-	bytesChan <- reader.count
+	atomic.AddInt64(&run.logicalBytes, atomic.LoadInt64(&logical.count))
+	bytesChan <- workerBytes{worker: worker, n: atomic.LoadInt64(&reader.count)}
 	return nil
 }
 
+// gzipStream compresses r on the fly. Closing the returned reader stops the
+// compressing goroutine.
+func gzipStream(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, r)
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// errDataCapReached ends a request body that would take the run past
+// --max-bytes
+var errDataCapReached = errors.New("data cap reached")
+
 type countingReader struct {
 	reader io.Reader
 	count  int64
+	total  *int64 // Optional running counter shared across requests
+	limit  int64  // Cap on total, 0 is none; needs total
 }
 
 func (r *countingReader) Read(p []byte) (int, error) {
+	if r.limit > 0 {
+		// Reserve room under the cap before reading, so concurrent
+		// requests cannot overshoot it together
+		for {
+			used := atomic.LoadInt64(r.total)
+			room := r.limit - used
+			if room <= 0 {
+				return 0, errDataCapReached
+			}
+			size := min(int64(len(p)), room)
+			if atomic.CompareAndSwapInt64(r.total, used, used+size) {
+				p = p[:size]
+				break
+			}
+		}
+		n, err := r.reader.Read(p)
+		atomic.AddInt64(&r.count, int64(n))
+		atomic.AddInt64(r.total, int64(n-len(p))) // Give back what was not read
+		return n, err
+	}
+
 	n, err := r.reader.Read(p)
 	atomic.AddInt64(&r.count, int64(n))
+	if r.total != nil {
+		atomic.AddInt64(r.total, int64(n))
+	}
 	return n, err
 }
 
-func generateTestData(size int) []byte {
+// generateTestData fills a payload with random bytes, or leaves it zeroed
+// for kind "zeros". Seeded runs draw from the package RNG so the payload is
+// identical across runs.
+func generateTestData(size int, kind string, seeded bool) []byte {
 	data := make([]byte, size)
+	if kind == "zeros" {
+		return data
+	}
+	if seeded {
+		randRead(data)
+		return data
+	}
 	if _, err := rand.Read(data); err != nil {
 		// Fall back to predictable pattern if random fails
 		for i := range data {
@@ -209,24 +668,216 @@ func parseUploadConfig(args []string) (*UploadConfig, error) {
 	if err := cmd.Parse(args); err != nil {
 		return nil, fmt.Errorf("parsing arguments: %w", err)
 	}
+	if err := applyFlagSources(cmd); err != nil {
+		return nil, err
+	}
+	return uploadConfigFromFlags(cmd)
+}
 
+// uploadConfigFromFlags builds and validates the configuration from the
+// parsed flags of an upload flag set
+func uploadConfigFromFlags(cmd *flag.FlagSet) (*UploadConfig, error) {
 	duration := cmd.Lookup("duration").Value.(flag.Getter).Get().(int)
+	if duration < 1 {
+		return nil, fmt.Errorf("invalid --duration %d (expected at least 1 second)", duration)
+	}
+
+	config := &UploadConfig{
+		URL:          cmd.Lookup("url").Value.String(),
+		Duration:     time.Duration(duration) * time.Second,
+		Concurrency:  cmd.Lookup("concurrency").Value.(flag.Getter).Get().(int),
+		Verbose:      cmd.Lookup("verbose").Value.(flag.Getter).Get().(bool),
+		Seed:         cmd.Lookup("seed").Value.(flag.Getter).Get().(int64),
+		RequireTLS13: cmd.Lookup("require-tls13").Value.(flag.Getter).Get().(bool),
+		Insecure:     cmd.Lookup("insecure").Value.(flag.Getter).Get().(bool),
+		Format:       cmd.Lookup("format").Value.String(),
+		Template:     cmd.Lookup("template").Value.String(),
+		File:         cmd.Lookup("file").Value.String(),
+		Data:         cmd.Lookup("data").Value.String(),
+		Compress:     cmd.Lookup("compress").Value.(flag.Getter).Get().(bool),
+		Out:          cmd.Lookup("out").Value.String(),
+		MinSpeed:     cmd.Lookup("min-upload").Value.(flag.Getter).Get().(float64),
+		Repeat:       cmd.Lookup("repeat").Value.(flag.Getter).Get().(int),
+		Interval:     cmd.Lookup("interval").Value.(flag.Getter).Get().(time.Duration),
+		Log:          cmd.Lookup("log").Value.String(),
+
+		Bufferbloat:       cmd.Lookup("bufferbloat").Value.(flag.Getter).Get().(bool),
+		BufferbloatTarget: cmd.Lookup("bufferbloat-target").Value.String(),
+
+		Warmup: cmd.Lookup("warmup").Value.(flag.Getter).Get().(time.Duration),
+
+		Burst: cmd.Lookup("burst").Value.(flag.Getter).Get().(bool),
+		RPS:   cmd.Lookup("rps").Value.(flag.Getter).Get().(bool),
+	}
+
+	if config.Concurrency < 1 {
+		return nil, fmt.Errorf("invalid --concurrency %d (expected at least 1 stream)", config.Concurrency)
+	}
+	if config.MinSpeed < 0 {
+		return nil, errors.New("--min-upload cannot be negative")
+	}
+	if err := parseRepeat(config.Repeat, config.Interval, config.Format); err != nil {
+		return nil, err
+	}
+
+	if config.Burst {
+		if flagSet(cmd, "duration") {
+			return nil, fmt.Errorf("--burst cannot be combined with --duration (burst mode always uploads for %v)", burstWindow)
+		}
+		config.Duration = burstWindow
+	}
+	if config.Warmup < 0 {
+		return nil, errors.New("--warmup cannot be negative")
+	}
+	if config.Warmup > 0 && config.Warmup >= config.Duration {
+		return nil, fmt.Errorf("--warmup (%v) leaves no measurement time in the %v test",
+			config.Warmup, config.Duration)
+	}
+
+	config.Server = cmd.Lookup("server").Value.(flag.Getter).Get().(int)
+	config.ServerList = cmd.Lookup("server-list").Value.String()
+	if config.ServerList == "" {
+		config.ServerList = DefaultServerListURL
+	}
+	if config.Server != 0 && config.URL != "" {
+		return nil, errors.New("--server and --url cannot be used together")
+	}
+
+	if config.URL == "" {
+		config.URL = DefaultUploadURL
+	}
+	if u, err := url.Parse(config.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid --url %q (expected an http or https URL)", config.URL)
+	}
+
+	if config.Data != "random" && config.Data != "zeros" {
+		return nil, fmt.Errorf("invalid --data %q (expected random or zeros)", config.Data)
+	}
 
-	return &UploadConfig{
-		Duration:    time.Duration(duration) * time.Second,
-		Concurrency: cmd.Lookup("concurrency").Value.(flag.Getter).Get().(int),
-		Verbose:     cmd.Lookup("verbose").Value.(flag.Getter).Get().(bool),
-	}, nil
+	var err error
+	config.Unit, err = parseSpeedUnit(cmd.Lookup("unit").Value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	config.HTTPVersion, err = parseHTTPVersion(cmd.Lookup("http").Value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	config.Proxy, err = parseProxyURL(cmd.Lookup("proxy").Value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	config.TLSCiphers, err = parseCipherSuites(cmd.Lookup("tls-ciphers").Value.String(), config.RequireTLS13)
+	if err != nil {
+		return nil, fmt.Errorf("parsing --tls-ciphers: %w", err)
+	}
+
+	config.ChunkSize, err = parseByteSize(cmd.Lookup("chunk-size").Value.String())
+	if err != nil {
+		return nil, fmt.Errorf("parsing --chunk-size: %w", err)
+	}
+	if config.ChunkSize <= 0 {
+		return nil, errors.New("--chunk-size must be greater than zero")
+	}
+	if config.RPS && !flagSet(cmd, "chunk-size") {
+		config.ChunkSize = rpsChunkSize
+	}
+
+	config.MaxBytes, err = parseMaxBytes(cmd.Lookup("max-bytes").Value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if config.File != "" {
+		if flagSet(cmd, "data") {
+			return nil, errors.New("--data cannot be combined with --file")
+		}
+		if err := checkUploadFile(config.File); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// flagSet reports whether the named flag was given on the command line
+func flagSet(cmd *flag.FlagSet, name string) bool {
+	found := false
+	cmd.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// checkUploadFile verifies that the --file payload exists, is a regular
+// non-empty file and can be opened for reading
+func checkUploadFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("upload file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("upload file %s is not a regular file", path)
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("upload file %s is empty", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("upload file: %w", err)
+	}
+	return file.Close()
 }
 
-func printUploadResults(stats UploadStats) {
-	fmt.Printf("\n\nUPLOAD TEST RESULTS\n")
-	fmt.Println(strings.Repeat("=", 50))
-	fmt.Printf("Total data sent: %.2f MB\n", float64(stats.BytesSent)/(1024*1024))
-	fmt.Printf("Test duration: %.1f seconds\n", stats.Duration.Seconds())
-	fmt.Printf("Average speed: %.2f Mbps\n", stats.Speed)
+func printUploadResults(w io.Writer, config *UploadConfig, stats UploadStats) {
+	fmt.Fprintf(w, "\n\nUPLOAD TEST RESULTS\n")
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+	if config.File != "" {
+		fmt.Fprintf(w, "Payload file: %s\n", config.File)
+	}
+	fmt.Fprintf(w, "Total data sent: %.2f MB\n", float64(stats.BytesSent)/(1024*1024))
+	if config.Compress {
+		fmt.Fprintf(w, "Uncompressed payload: %.2f MB\n", float64(stats.LogicalBytes)/(1024*1024))
+	}
+	fmt.Fprintf(w, "Test duration: %.1f seconds\n", stats.Duration.Seconds())
+	if config.RPS {
+		fmt.Fprintf(w, "Requests: %d (%.1f req/s)\n", stats.Requests, stats.RequestsPerSecond)
+		fmt.Fprintf(w, "Request latency: p50 %.1fms, p95 %.1fms\n",
+			float64(stats.RequestP50.Microseconds())/1000,
+			float64(stats.RequestP95.Microseconds())/1000)
+	} else {
+		fmt.Fprintf(w, "Average speed: %s\n", speedText(stats.Speed, stats.Duration, config.Unit))
+	}
+	if config.Burst {
+		fmt.Fprintf(w, "Peak burst speed (%v): %s\n", burstSampleInterval, formatSpeed(stats.PeakSpeed, config.Unit))
+	}
+	if len(stats.Protocols) > 0 {
+		fmt.Fprintf(w, "Protocol: %s\n", strings.Join(stats.Protocols, ", "))
+	}
+	printRequestPhases(w, stats.Phases)
+	if stats.Latency != nil {
+		printLoadedLatency(w, stats.Latency)
+	}
+	if stats.RateLimited > 0 {
+		fmt.Fprintf(w, "Rate limited: %d times\n", stats.RateLimited)
+	}
+	if config.MaxBytes > 0 {
+		printDataUsed(w, stats.DataUsed, config.MaxBytes, stats.DataCapped)
+	}
+	if stats.Cancelled {
+		fmt.Fprintf(w, "Interrupted after %.1f seconds, results are partial\n", stats.Duration.Seconds())
+	}
 	if stats.Error != nil {
-		fmt.Printf("Errors encountered: %v\n", stats.Error)
+		fmt.Fprintf(w, "Errors encountered: %v\n", stats.Error)
+	}
+	if config.Verbose {
+		printWorkerStats(w, stats.Workers, config.Unit)
 	}
-	fmt.Println(strings.Repeat("=", 50))
+	fmt.Fprintln(w, strings.Repeat("=", 50))
 }
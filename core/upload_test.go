@@ -0,0 +1,218 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"speedgo/commands"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetFlags puts the flags of a command's global flag set back to their
+// defaults when the test ends, so parses in later tests start clean. Flags
+// stay marked as given on the command line, though, so cases that set a
+// flag from the environment must run before cases that pass it as a flag.
+func resetFlags(t *testing.T, cmd *flag.FlagSet) {
+	t.Helper()
+	t.Cleanup(func() {
+		cmd.VisitAll(func(f *flag.Flag) { f.Value.Set(f.DefValue) })
+	})
+}
+
+func TestParseUploadConfigURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{"default endpoint", nil, DefaultUploadURL, false},
+		{"custom endpoint", []string{"--url=https://upload.example.net/up"}, "https://upload.example.net/up", false},
+		{"plain http", []string{"--url=http://10.0.0.1:8080/"}, "http://10.0.0.1:8080/", false},
+		{"unsupported scheme", []string{"--url=ftp://upload.example.net/"}, "", true},
+		{"no host", []string{"--url=https:///up"}, "", true},
+		{"not a url", []string{"--url=upload.example.net"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(t, commands.UploadCmd)
+			config, err := parseUploadConfig(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseUploadConfig(%q) = %q, want an error", tt.args, config.URL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseUploadConfig(%q): %v", tt.args, err)
+			}
+			if config.URL != tt.want {
+				t.Errorf("URL = %q, want %q", config.URL, tt.want)
+			}
+		})
+	}
+}
+
+// TestUploadShortRun is meant for go test -race. The server answering
+// before it has read the body leaves the transport still reading it while
+// the worker reports the chunk.
+func TestUploadShortRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"reads body", func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+		}},
+		{"answers early", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			stats := measureUploadSpeed(context.Background(), &UploadConfig{
+				URL:         server.URL,
+				Duration:    300 * time.Millisecond,
+				Concurrency: 4,
+				ChunkSize:   4 << 20,
+			})
+
+			if stats.BytesSent == 0 {
+				t.Fatalf("BytesSent = 0, error %v", stats.Error)
+			}
+			if stats.Speed <= 0 {
+				t.Errorf("Speed = %v, want > 0", stats.Speed)
+			}
+		})
+	}
+}
+
+func TestUploadInterrupted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	stats := measureUploadSpeed(ctx, &UploadConfig{
+		URL:         server.URL,
+		Duration:    30 * time.Second,
+		Concurrency: 2,
+		ChunkSize:   64 * 1024,
+	})
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned %v after the start, want soon after the interrupt", elapsed)
+	}
+	if !stats.Cancelled {
+		t.Error("Cancelled = false, want true")
+	}
+	if stats.Error != nil {
+		t.Errorf("Error = %v, want none for requests cut off by the interrupt", stats.Error)
+	}
+	if stats.BytesSent == 0 {
+		t.Error("BytesSent = 0, want the partial results")
+	}
+}
+
+func TestParseUploadConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"zero duration", []string{"--duration=0"}, "invalid --duration 0"},
+		{"negative duration", []string{"--duration=-3"}, "invalid --duration -3"},
+		{"zero concurrency", []string{"--concurrency=0"}, "invalid --concurrency 0"},
+		{"negative concurrency", []string{"--concurrency=-1"}, "invalid --concurrency -1"},
+		{"warmup", []string{"--duration=10", "--warmup=2s"}, ""},
+		{"negative warmup", []string{"--warmup=-1s"}, "--warmup cannot be negative"},
+		{"warmup as long as the test", []string{"--duration=5", "--warmup=5s"}, "no measurement time in the 5s test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(t, commands.UploadCmd)
+			_, err := parseUploadConfig(tt.args)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("parseUploadConfig(%q): %v", tt.args, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseUploadConfig(%q) error = %v, want one containing %q", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUploadBurstDuration(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		wantDuration time.Duration
+		wantErr      string
+	}{
+		{"burst alone", []string{"--burst"}, burstWindow, ""},
+		{"duration alone", []string{"--duration=5"}, 5 * time.Second, ""},
+		{"burst and duration", []string{"--burst", "--duration=30"}, 0, "--burst cannot be combined with --duration"},
+		{"warmup past the burst window", []string{"--burst", "--warmup=3s"}, 0, "no measurement time in the 2s test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A fresh flag set, as the global one keeps flags marked as given
+			cmd := commands.NewUploadCmd()
+			if err := cmd.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			config, err := uploadConfigFromFlags(cmd)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("uploadConfigFromFlags(%q) error = %v, want one containing %q", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("uploadConfigFromFlags(%q): %v", tt.args, err)
+			}
+			if config.Duration != tt.wantDuration {
+				t.Errorf("Duration = %v, want %v", config.Duration, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func TestCheckUpload(t *testing.T) {
+	tests := []struct {
+		name     string
+		minSpeed float64
+		speed    float64
+		want     error
+	}{
+		{"no minimum", 0, 0, nil},
+		{"slow", 50, 49.99, ErrBelowMinSpeed},
+		{"at the minimum", 50, 50, nil},
+		{"fast", 50, 120, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkUpload(&UploadConfig{MinSpeed: tt.minSpeed}, UploadStats{Speed: tt.speed})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("checkUpload() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+// Package core window.go
+package core
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// measureWindow tracks the part of a transfer test that counts toward its
+// speed: from the start (or the end of --warmup) to now, minus pauses. It
+// is read by the progress ticker while the aggregator may restart it.
+type measureWindow struct {
+	clock        Clock
+	pause        *pauseController
+	start        atomic.Int64 // Clock reading in Unix nanoseconds
+	pausedBefore atomic.Int64 // pause.pausedFor() at start
+}
+
+func newMeasureWindow(clock Clock, pause *pauseController) *measureWindow {
+	w := &measureWindow{clock: clock, pause: pause}
+	w.restart()
+	return w
+}
+
+// restart begins the window again from now
+func (w *measureWindow) restart() {
+	w.start.Store(w.clock.Now().UnixNano())
+	w.pausedBefore.Store(int64(w.pause.pausedFor()))
+}
+
+// elapsed returns the unpaused time since the window started
+func (w *measureWindow) elapsed() time.Duration {
+	paused := w.pause.pausedFor() - time.Duration(w.pausedBefore.Load())
+	return w.clock.Since(time.Unix(0, w.start.Load())) - paused
+}
@@ -0,0 +1,58 @@
+// Package core workers.go
+package core
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// stragglerFraction marks a worker as slow in the breakdown when it moved
+// less than this share of the per-worker average
+const stragglerFraction = 0.5
+
+// workerBytes reports bytes moved by one transfer worker
+type workerBytes struct {
+	worker int
+	n      int64
+}
+
+// WorkerStats is the part of a transfer carried by one worker
+type WorkerStats struct {
+	Worker int
+	Bytes  int64
+	Speed  float64 // Speed in Mbps
+}
+
+// workerBreakdown turns per-worker byte counts into speeds over d
+func workerBreakdown(bytes []int64, d time.Duration) []WorkerStats {
+	workers := make([]WorkerStats, len(bytes))
+	for i, n := range bytes {
+		workers[i] = WorkerStats{Worker: i, Bytes: n, Speed: mbps(n, d)}
+	}
+	return workers
+}
+
+// printWorkerStats lists what each worker moved, flagging stragglers whose
+// connection stalled or was much slower than the rest
+func printWorkerStats(w io.Writer, workers []WorkerStats, unit string) {
+	if len(workers) == 0 {
+		return
+	}
+
+	var total int64
+	for _, worker := range workers {
+		total += worker.Bytes
+	}
+	average := float64(total) / float64(len(workers))
+
+	fmt.Fprintln(w, "Per-worker breakdown:")
+	for _, worker := range workers {
+		note := ""
+		if len(workers) > 1 && float64(worker.Bytes) < stragglerFraction*average {
+			note = "  (slow)"
+		}
+		fmt.Fprintf(w, "  worker %-3d %10.2f MB  %s%s\n", worker.Worker,
+			float64(worker.Bytes)/(1024*1024), formatSpeed(worker.Speed, unit), note)
+	}
+}
@@ -4,4 +4,6 @@ go 1.23.2
 
 require golang.org/x/net v0.33.0
 
-require golang.org/x/sys v0.28.0 // indirect
+require golang.org/x/sys v0.28.0
+
+require golang.org/x/term v0.27.0
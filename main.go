@@ -4,13 +4,21 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"speedgo/commands"
 	"speedgo/core"
+	"syscall"
 )
 
 func main() {
-	// Create a base context that can be used across the application
-	ctx := context.Background()
+	// Ctrl-C or SIGTERM stops the running test, which then reports what it
+	// measured so far. A second signal kills the process as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
 	if len(os.Args) < 2 {
 		printHelp()
@@ -36,6 +44,33 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+	case "quality", "q":
+		if err := qualityCommand(ctx, args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "trace", "t":
+		if err := traceCommand(ctx, args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "test", "all":
+		if err := testCommand(ctx, args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "servers", "--server-list":
+		if err := serversCommand(ctx, args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "history", "h":
+		if err := historyCommand(ctx, args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "defaults", "--list-defaults":
+		printDefaults()
 	case "-h", "--help":
 		printHelp()
 	default:
@@ -48,17 +83,51 @@ func main() {
 func printHelp() {
 	fmt.Println("Usage: speedgo <command> [options]")
 	fmt.Println("\nCommands:")
+	fmt.Println("  test, all      Run a full test: latency, download and upload")
 	fmt.Println("  ping, p        Test network latency (ping multiple targets)")
 	fmt.Println("  download, d    Test download speed")
 	fmt.Println("  upload, u      Test upload speed")
+	fmt.Println("  quality, q     Rate the connection for voice calls (jitter, loss, MOS)")
+	fmt.Println("  trace, t       Show the routers on the path to a host (traceroute)")
+	fmt.Println("  servers        List test servers, closest first, for --server")
+	fmt.Println("  history, h     Summarize results saved with --log")
+	fmt.Println("  defaults       List the built-in endpoints speedgo connects to")
 	fmt.Println("\nExamples:")
+	fmt.Println("  speedgo test --duration=15s")
+	fmt.Println("  speedgo test --bidirectional --duration=20s")
 	fmt.Println("  speedgo ping --targets=google.com --count=5")
 	fmt.Println("  speedgo d --url=http://example.com/file.dat --duration=15")
 	fmt.Println("  speedgo u --file=test.dat --url=http://example.com/upload")
+	fmt.Println("  speedgo d --repeat=10 --interval=60s")
+	fmt.Println("  speedgo d --min-download=100 || echo \"download too slow\"")
+	fmt.Println("  speedgo q --targets=1.1.1.1 --count=100")
+	fmt.Println("  speedgo trace --max-hops=20 example.com")
+	fmt.Println("  speedgo servers --limit=5 && speedgo d --server=51")
+	fmt.Println("  speedgo test --log=results.jsonl && speedgo history results.jsonl")
+	fmt.Println("\nEnvironment:")
+	fmt.Println("  SPEEDGO_<COMMAND>_<FLAG>    Set a flag not given on the command line, e.g.")
+	fmt.Println("                              SPEEDGO_PING_TARGETS=1.1.1.1 or SPEEDGO_DOWNLOAD_MAX_RETRIES=3")
 	fmt.Println("\nHelp:")
 	fmt.Println("  speedgo <command> -h    Show help for a specific command")
 }
 
+// printDefaults lists every built-in endpoint so users can vet outbound
+// connections before running a test
+func printDefaults() {
+	fmt.Println("Ping targets:")
+	for _, target := range core.DefaultPingTargets {
+		fmt.Printf("  %s\n", target)
+	}
+	fmt.Println("\nDownload URLs:")
+	for _, url := range core.DefaultDownloadURLs {
+		fmt.Printf("  %s\n", url)
+	}
+	fmt.Println("\nUpload endpoint:")
+	fmt.Printf("  %s\n", core.DefaultUploadURL)
+	fmt.Println("\nServer list:")
+	fmt.Printf("  %s\n", core.DefaultServerListURL)
+}
+
 func pingCommand(ctx context.Context, args []string) error {
 	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
 		commands.PingCmd.Usage()
@@ -82,3 +151,43 @@ func uploadCommand(ctx context.Context, args []string) error {
 	}
 	return core.RunUpload(ctx, args)
 }
+
+func qualityCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		commands.QualityCmd.Usage()
+		return nil
+	}
+	return core.RunQuality(ctx, args)
+}
+
+func traceCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		commands.TraceCmd.Usage()
+		return nil
+	}
+	return core.RunTrace(ctx, args)
+}
+
+func testCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		commands.TestCmd.Usage()
+		return nil
+	}
+	return core.RunSpeedTest(ctx, args)
+}
+
+func serversCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		commands.ServersCmd.Usage()
+		return nil
+	}
+	return core.RunServers(ctx, args)
+}
+
+func historyCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		commands.HistoryCmd.Usage()
+		return nil
+	}
+	return core.RunHistory(ctx, args)
+}